@@ -1,28 +1,71 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
 	"forza-horizon-5-telemetry/client/ui"
-	"forza-horizon-5-telemetry/debugtools/debugstreamreader"
+	"forza-horizon-5-telemetry/shared/bus"
+	"forza-horizon-5-telemetry/shared/dynamics"
+	"forza-horizon-5-telemetry/shared/ingest"
 	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/replay"
+	"forza-horizon-5-telemetry/shared/sequencing"
+	"forza-horizon-5-telemetry/shared/source"
+	"forza-horizon-5-telemetry/shared/units"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
-	//"github.com/gdamore/tcell/v2"
 	"log"
 
+	"github.com/gdamore/tcell/v2"
+
 	"github.com/rivo/tview"
 )
 
 const (
-	defaultUpdatesPerSecond = 10 // Default update rate
+	defaultFPS     = 10               // Default redraw rate
+	replaySeekStep = 10 * time.Second // How far the arrow keys jump in a replay
 )
 
 func main() {
 	debugMode := flag.Bool("debug", false, "Use debug stream instead of UDP")
-	debugFile := flag.String("debugfile", "debugstream", "Path to debug stream file")
+	debugFile := flag.String("debugfile", "debugstream", "Path to debug stream file or pcap / pcapng capture")
+	replaySpeed := flag.Float64("speed", 1, "Debug replay speed, 0.25 to 8")
+	replayClock := flag.String("clock", "auto", "What paces the debug replay: received (recorded receive times), timestamp (TimeStampMS) or auto")
+	replayLoop := flag.Bool("loop", true, "Loop the debug replay, otherwise stop at the end")
+	debugPacketSize := flag.Int("debugpacketsize", 324, "Packet size in the debug stream file (232, 311, 324 or 331)")
+	unitSystem := flag.String("units", "metric", "Units to display: metric or imperial")
+	listenConfigs := packethandling.ListenFlags(flag.CommandLine)
+	relayDests := packethandling.RelayFlags(flag.CommandLine)
+	sourceURI := flag.String("source", "", source.Usage)
+	fps := flag.Int("fps", defaultFPS, "How many times a second to redraw the dashboard")
+	stallAfter := flag.Duration("stall", ingest.DefaultStallAfter, "How long without packets before the stream shows as stalled")
 	flag.Parse()
 
+	listeners, err := listenConfigs()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *fps <= 0 {
+		log.Fatalf("-fps must be above 0, got %d", *fps)
+	}
+
+	clock, err := replay.ParseClock(*replayClock)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	system, err := units.ParseSystem(*unitSystem)
+	if err != nil {
+		log.Fatal(err)
+	}
+	prefs := units.ForSystem(system)
+
 	app := tview.NewApplication()
 
 	// Create main flex container (vertical)
@@ -52,7 +95,7 @@ func main() {
 	bottomFlex.AddItem(speedometer, 7, 0, false) // Fixed 7 lines for speedometer
 
 	// Add both flexboxes to main container with fixed heights
-	normalView.AddItem(topFlex, 8, 0, false)     // Fixed 8 lines for info panels
+	normalView.AddItem(topFlex, 9, 0, false)     // Fixed 9 lines for info panels
 	normalView.AddItem(bottomFlex, 10, 0, false) // Fixed 10 lines for meters
 
 	// Create debug view (modify this part)
@@ -77,98 +120,231 @@ func main() {
 		}
 	})
 
+	// Connection state and stream health along the bottom of the normal view
+	statusBar := ui.CreateStatusBar()
+	normalView.AddItem(statusBar, 3, 0, false)
+
 	// Add button to main flex at top
 	mainFlex.AddItem(toggleButton, 1, 0, false)
 	// Add normal view as default
 	mainFlex.AddItem(normalView, 0, 1, true)
 
-	textView := tview.NewTextView().
-		SetTextAlign(tview.AlignLeft).
-		SetDynamicColors(true).
-		SetWrap(true).
-		SetScrollable(true).
-		SetText("Waiting for data...").
-		SetChangedFunc(func() {
-			app.Draw()
+	// Watches TimeStampMS for dropped / duplicated / reordered packets, one per source
+	// as each game instance has its own clock
+	var trackersMu sync.Mutex
+	trackers := map[string]*sequencing.Tracker{}
+	trackerFor := func(name string) *sequencing.Tracker {
+		trackersMu.Lock()
+		defer trackersMu.Unlock()
+		tracker, ok := trackers[name]
+		if !ok {
+			tracker = sequencing.NewTracker(sequencing.DefaultInterval)
+			trackers[name] = tracker
+		}
+		return tracker
+	}
+
+	// Work out where the packets come from, -source wins, then -debug, then the UDP listeners
+	var src source.PacketSource
+	var player *replay.Player
+	switch {
+	case *sourceURI != "":
+		src, err = source.Open(*sourceURI)
+	case *debugMode:
+		player, err = replay.OpenFile(*debugFile, *debugPacketSize, replay.Options{
+			Clock:     clock,
+			Speed:     *replaySpeed,
+			Loop:      *replayLoop,
+			HoldAtEnd: true,
 		})
+		src = player
+	default:
+		src, err = source.NewUDP(listeners)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer src.Close()
 
-	// Create ticker for rate limiting
-	updatesPerSecond := defaultUpdatesPerSecond
-	ticker := time.NewTicker(time.Second / time.Duration(updatesPerSecond))
-	defer ticker.Stop()
+	var relayConns []*net.UDPConn
+	if udp, ok := src.(*source.UDP); ok {
+		relayConns = udp.Conns()
+	}
 
-	go func() {
-		var readPacket func() ([]byte, error)
+	// Forward everything on to other tools, the game can only send to one place
+	var relay *packethandling.Relay
+	if len(*relayDests) > 0 {
+		relay, err = packethandling.NewRelay(relayConns, *relayDests)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer relay.Close()
+	}
 
-		if *debugMode {
-			reader, err := debugstreamreader.NewDebugStreamReader(*debugFile, 324)
-			if err != nil {
-				log.Fatal(err)
+	// Replay controls for -debug
+	var replayBar *tview.TextView
+	var replayMessage string // Result of the last replay key, e.g. a lap that isn't there
+	if player != nil {
+		replayBar = ui.CreateReplayBar()
+		normalView.AddItem(replayBar, 2, 0, false)
+
+		app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			status := player.Status()
+			var err error
+
+			switch event.Key() {
+			case tcell.KeyLeft:
+				err = player.SeekTime(status.Position - replaySeekStep)
+			case tcell.KeyRight:
+				err = player.SeekTime(status.Position + replaySeekStep)
+			case tcell.KeyHome:
+				err = player.SeekTime(0)
+			case tcell.KeyRune:
+				switch event.Rune() {
+				case ' ':
+					player.TogglePause()
+				case '.':
+					player.Step()
+				case '+', '=':
+					player.Faster()
+				case '-':
+					player.Slower()
+				case '[':
+					if status.Lap > 0 {
+						err = player.SeekLap(status.Lap - 1)
+					}
+				case ']':
+					err = player.SeekLap(status.Lap + 1)
+				default:
+					return event
+				}
+			default:
+				return event
 			}
-			readPacket = reader.ReadNext
-		} else {
 
-			conn, err := packethandling.Setup("127.0.0.1", 9999)
+			replayMessage = ""
 			if err != nil {
-				log.Fatal(err)
+				replayMessage = err.Error()
 			}
-			defer conn.Close()
+			ui.UpdateReplayBar(replayBar, player.Status(), replayMessage)
+			return nil
+		})
+	}
 
-			buf := make([]byte, 1024)
-			readPacket = func() ([]byte, error) {
-				n, _, err := conn.ReadFromUDP(buf)
-				if err != nil {
-					return nil, err
-				}
-				return buf[:n], nil
-			}
+	// Quit on Ctrl-C / SIGTERM as well as when the app closes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, app.Stop)
 
-		}
-		var fh5Packet packethandling.ForzaHorizon5Packet
+	monitor := ingest.NewMonitor(*stallAfter)
 
-		for {
+	// The read loop relays and tracks every packet itself, so neither can miss one
+	// because a subscriber fell behind. Everything else hangs off the bus.
+	packets := bus.New()
+	onPacket := func(pkt source.Packet, p *packethandling.ForzaHorizon5Packet, err error) {
+		// Relay everything, malformed packets included
+		if relay != nil {
+			relay.Forward(pkt.Data, p)
+		}
 
-			data, err := readPacket()
-			if err != nil {
-				app.QueueUpdateDraw(func() {
-					textView.SetText(fmt.Sprintf("Read error: %v", err))
-				})
-				return
+		if p != nil {
+			received := pkt.Received
+			if received.IsZero() {
+				received = time.Now()
 			}
+			trackerFor(pkt.Source).Observe(p.TimeStampMS, received)
+		}
 
-			err = packethandling.ParsePacket(data, &fh5Packet)
-			if err != nil {
-				app.QueueUpdateDraw(func() {
-					textView.SetText(fmt.Sprintf("Error parsing packet: %v", err))
-				})
-				return
-			}
+		packets.PublishPacket(pkt, p, err)
+	}
 
-			// Wait for ticker before updating UI
-			select {
-			case <-ticker.C:
-
-				app.QueueUpdateDraw(func() {
-					if !isDebugView {
-						ui.UpdateRPMMeter(rpmMeter, fh5Packet.GetCurrentEngineRpm(), fh5Packet.GetEngineMaxRpm())
-						ui.UpdateSpeedometer(speedometer, fh5Packet.GetSpeedKMH())
-						ui.UpdateLeftInfoPanel(leftInfoPanel, fh5Packet)
-						ui.UpdateRightInfoPanel(rightInfoPanel, fh5Packet)
-					} else {
-						// Update debug view
-						ui.UpdateDebugView(debugView, fh5Packet)
-					}
-				})
+	// Newest packet for the renderer, written at the packet rate and read at the frame rate
+	var latest bus.Latest[frame]
 
-			default:
-				// No tick yet
+	// The tire radius is smoothed over every packet here, not just the ones that get drawn
+	uiSub := packets.Subscribe(bus.PolicyQueue, bus.DefaultQueueSize)
+	go func() {
+		var radius dynamics.TireRadius
+		for m := range uiSub.C() {
+			if m.Err != nil {
 				continue
 			}
+			latest.Store(frame{packet: m.Packet, source: m.Source, tracker: trackerFor(m.Source), tireRadius: radius.Update(&m.Packet)})
+		}
+	}()
 
+	updateStatus := func(name string, tracker *sequencing.Tracker, perFrame uint64) {
+		var stats sequencing.Stats
+		if tracker != nil {
+			stats = tracker.Stats()
 		}
+		var relayStats []packethandling.RelayStats
+		if relay != nil {
+			relayStats = relay.Stats()
+		}
+		ui.UpdateStatusBar(statusBar, monitor.Status(time.Now()), name, stats, perFrame, uiSub.Dropped(), relayStats)
+	}
+
+	go func() {
+		defer packets.Close()
+		ingest.Run(ctx, src, ingest.Config{
+			Monitor: monitor,
+			Packet:  onPacket,
+		})
 	}()
 
+	// Render clock, redraws at -fps whether or not packets are arriving so the
+	// connection state keeps up to date
+	go func() {
+		clock := time.NewTicker(time.Second / time.Duration(*fps))
+		defer clock.Stop()
+
+		var lastSeq uint64
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-clock.C:
+			}
+
+			f, seq, ok := latest.Load()
+			perFrame := seq - lastSeq
+			lastSeq = seq
+
+			app.QueueUpdateDraw(func() {
+				if player != nil {
+					ui.UpdateReplayBar(replayBar, player.Status(), replayMessage)
+				}
+				if !ok {
+					updateStatus(src.Name(), nil, 0)
+					return
+				}
+				if !isDebugView {
+					if perFrame > 0 {
+						ui.UpdateRPMMeter(rpmMeter, f.packet.GetCurrentEngineRpm(), f.packet.GetEngineMaxRpm())
+						ui.UpdateSpeedometer(speedometer, f.packet.GetSpeed(), prefs.Speed)
+						ui.UpdateLeftInfoPanel(leftInfoPanel, f.packet, prefs)
+						ui.UpdateRightInfoPanel(rightInfoPanel, f.packet)
+					}
+					updateStatus(f.source, f.tracker, perFrame)
+				} else if perFrame > 0 {
+					// Update debug view
+					ui.UpdateDebugView(debugView, f.packet, f.tireRadius)
+				}
+			})
+		}
+	}()
+
+	updateStatus(src.Name(), nil, 0)
 	if err := app.SetRoot(mainFlex, true).EnableMouse(true).Run(); err != nil {
 		panic(err)
 	}
 }
+
+// frame is what the renderer needs from the newest packet
+type frame struct {
+	packet     packethandling.ForzaHorizon5Packet
+	source     string
+	tracker    *sequencing.Tracker
+	tireRadius float64
+}
@@ -1,7 +1,11 @@
 package ui
 
 import (
+	"fmt"
+	"forza-horizon-5-telemetry/shared/dynamics"
 	"forza-horizon-5-telemetry/shared/packethandling"
+	"math"
+	"strings"
 
 	"github.com/rivo/tview"
 )
@@ -18,6 +22,28 @@ func CreateDebugView() *tview.TextView {
 	return debugView
 }
 
-func UpdateDebugView(debugView *tview.TextView, data packethandling.ForzaHorizon5Packet) {
-	debugView.SetText(packethandling.FormatStruct(data))
+// UpdateDebugView shows a packet, tireRadius is the dynamics.TireRadius estimate kept across every packet
+func UpdateDebugView(debugView *tview.TextView, data packethandling.ForzaHorizon5Packet, tireRadius float64) {
+	var sb strings.Builder
+
+	report := packethandling.Validate(&data)
+	sb.WriteString(fmt.Sprintf("Status: %s\n", report.Class))
+	for _, issue := range report.Issues {
+		color := "yellow"
+		if issue.Severity == packethandling.SeverityError {
+			color = "red"
+		}
+		sb.WriteString(fmt.Sprintf("[%s]%s[white]\n", color, tview.Escape(issue.String())))
+	}
+
+	// Derived channels, the wheel speed is only there once the tires have gripped enough to size them
+	derived := dynamics.Derive(&data, tireRadius)
+	sb.WriteString("\nDerived\n")
+	sb.WriteString(fmt.Sprintf("    Lateral / Longitudinal / Vertical G    %.2f / %.2f / %.2f\n", derived.LateralG, derived.LongitudinalG, derived.VerticalG))
+	sb.WriteString(fmt.Sprintf("    Slip Angle                             %.1f deg\n", derived.SlipAngle*180/math.Pi))
+	sb.WriteString(fmt.Sprintf("    Yaw Rate                               %.1f deg/s\n", derived.YawRate*180/math.Pi))
+	sb.WriteString(fmt.Sprintf("    Speed Over Ground / Wheel Speed        %.1f / %.1f m/s\n\n", derived.SpeedOverGround, derived.WheelSpeed))
+
+	sb.WriteString(tview.Escape(packethandling.FormatStruct(data)))
+	debugView.SetText(sb.String())
 }
@@ -3,6 +3,7 @@ package ui
 import (
 	"fmt"
 	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/units"
 	"strings"
 
 	"github.com/rivo/tview"
@@ -16,16 +17,22 @@ func CreateInfoPanel() *tview.TextView {
 	return tv
 }
 
-func UpdateLeftInfoPanel(panel *tview.TextView, dash packethandling.ForzaHorizon5Packet) {
+func UpdateLeftInfoPanel(panel *tview.TextView, dash packethandling.ForzaHorizon5Packet, prefs units.Preferences) {
 	var sb strings.Builder
 
 	sb.WriteString("[yellow]Car Information[white]\n")
 	sb.WriteString("---------------\n")
-	sb.WriteString(fmt.Sprintf("Speed: %.0f km/h\n", dash.GetSpeedKMH()))
-	sb.WriteString(fmt.Sprintf("Power: %.0f hp\n", dash.GetPower()))
-	sb.WriteString(fmt.Sprintf("Torque: %.0f Nm\n", dash.GetTorque()))
-	sb.WriteString(fmt.Sprintf("Car Class: %d\n", dash.GetCarClass()))
-	sb.WriteString(fmt.Sprintf("PI: %d\n", dash.GetCarPerformanceIndex()))
+	sb.WriteString(fmt.Sprintf("Speed: %s\n", prefs.FormatSpeed(float64(dash.Speed))))
+	sb.WriteString(fmt.Sprintf("Power: %s\n", prefs.FormatPower(float64(dash.Power))))
+	sb.WriteString(fmt.Sprintf("Torque: %s\n", prefs.FormatTorque(float64(dash.Torque))))
+	sb.WriteString(fmt.Sprintf("Boost: %s\n", prefs.FormatPressure(float64(dash.GetBoost()))))
+	fl, fr, rl, rr := dash.GetTireTemperatures()
+	sb.WriteString(fmt.Sprintf("Tires Front: %s / %s\n", prefs.FormatTemperature(float64(fl)), prefs.FormatTemperature(float64(fr))))
+	sb.WriteString(fmt.Sprintf("Tires Rear: %s / %s\n", prefs.FormatTemperature(float64(rl)), prefs.FormatTemperature(float64(rr))))
+	sb.WriteString(fmt.Sprintf("Car: %s\n", dash.GetCarSummary()))
+	if dash.Format == packethandling.FormatHorizon {
+		sb.WriteString(fmt.Sprintf("Type: %s\n", dash.GetCarType()))
+	}
 
 	panel.SetText(sb.String())
 }
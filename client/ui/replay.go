@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"fmt"
+	"forza-horizon-5-telemetry/shared/replay"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// ReplayKeys is the help text for the replay key bindings
+const ReplayKeys = "space pause | . step | +/- speed | ←/→ 10s | [/] lap | home restart"
+
+func CreateReplayBar() *tview.TextView {
+	return tview.NewTextView().
+		SetTextAlign(tview.AlignLeft).
+		SetDynamicColors(true)
+}
+
+// UpdateReplayBar shows where the replay is up to, message is the result of the last key press if any
+func UpdateReplayBar(bar *tview.TextView, status replay.Status, message string) {
+	var sb strings.Builder
+
+	state := "[green]playing[-]"
+	switch {
+	case status.Ended:
+		state = "[red]ended[-]"
+	case status.Paused:
+		state = "[yellow]paused[-]"
+	}
+	sb.WriteString(fmt.Sprintf("Replay %s %gx | %s", state, status.Speed, formatPosition(status.Position)))
+	if status.HasLap {
+		sb.WriteString(fmt.Sprintf(" | lap %d", status.Lap))
+	}
+	if status.Loops > 0 {
+		sb.WriteString(fmt.Sprintf(" | loop %d", status.Loops))
+	}
+	if message != "" {
+		sb.WriteString(" | " + tview.Escape(message))
+	}
+	sb.WriteString("\n[gray]" + ReplayKeys + "[-]")
+
+	bar.SetText(sb.String())
+}
+
+// formatPosition shows a position as mm:ss.t, or h:mm:ss.t past an hour
+func formatPosition(d time.Duration) string {
+	tenths := d.Milliseconds() / 100
+	h := tenths / 36000
+	m := tenths / 600 % 60
+	s := tenths / 10 % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d.%d", h, m, s, tenths%10)
+	}
+	return fmt.Sprintf("%02d:%02d.%d", m, s, tenths%10)
+}
@@ -2,23 +2,39 @@ package ui
 
 import (
 	"fmt"
+	"forza-horizon-5-telemetry/shared/units"
 	"github.com/rivo/tview"
 	"strings"
 )
 
 const (
-	speedometerWidth = 50  // Increased width for better detail
-	maxSpeedDisplay  = 400 // Maximum speed in km/h
-	speedSegments    = 8   // Number of major segments
+	speedometerWidth = 50 // Increased width for better detail
+	speedSegments    = 8  // Number of major segments
 )
 
+// Maximum speed on the dial for each unit, multiples of speedSegments so the labels stay round
+func speedDialMax(unit units.SpeedUnit) float32 {
+	switch unit {
+	case units.MPH:
+		return 240
+	case units.MPS:
+		return 112
+	default:
+		return 400
+	}
+}
+
 func CreateSpeedometer() *tview.TextView {
 	return tview.NewTextView().
 		SetTextAlign(tview.AlignCenter).
 		SetDynamicColors(true)
 }
 
-func UpdateSpeedometer(meter *tview.TextView, speedKMH float32) {
+// UpdateSpeedometer draws the dial, speed is in m/s and shown in the given unit
+func UpdateSpeedometer(meter *tview.TextView, speedMPS float32, unit units.SpeedUnit) {
+	speed := float32(unit.FromMPS(float64(speedMPS)))
+	maxSpeed := speedDialMax(unit)
+
 	var sb strings.Builder
 
 	// Create more detailed arc shape
@@ -34,20 +50,20 @@ func UpdateSpeedometer(meter *tview.TextView, speedKMH float32) {
 	}
 
 	// Add digital speed with larger format
-	sb.WriteString(fmt.Sprintf("[yellow]%3.0f[white]km/h\n", speedKMH))
+	sb.WriteString(fmt.Sprintf("[yellow]%3.0f[white]%s\n", speed, unit))
 
 	// Create speed markers with labels
-	markerLine := createSpeedMarkers(speedKMH)
+	markerLine := createSpeedMarkers(speed, maxSpeed)
 	sb.WriteString(markerLine + "\n")
 
 	// Add speed labels
-	speedLabels := createSpeedLabels()
+	speedLabels := createSpeedLabels(maxSpeed)
 	sb.WriteString(speedLabels)
 
 	meter.SetText(sb.String())
 }
 
-func createSpeedMarkers(speed float32) string {
+func createSpeedMarkers(speed, maxSpeedDisplay float32) string {
 	var sb strings.Builder
 	percentage := speed / maxSpeedDisplay
 	needlePos := int(percentage * float32(speedometerWidth))
@@ -109,12 +125,12 @@ func createSpeedMarkers(speed float32) string {
 	return sb.String()
 }
 
-func createSpeedLabels() string {
+func createSpeedLabels(maxSpeedDisplay float32) string {
 	var sb strings.Builder
 	sb.WriteString(strings.Repeat(" ", 3))
 
 	for i := 0; i <= speedSegments; i++ {
-		speed := (i * maxSpeedDisplay) / speedSegments
+		speed := (i * int(maxSpeedDisplay)) / speedSegments
 		if i == 0 {
 			sb.WriteString(fmt.Sprintf("[gray]%-6d", speed))
 		} else if i == speedSegments {
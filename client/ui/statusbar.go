@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"fmt"
+	"forza-horizon-5-telemetry/shared/ingest"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/sequencing"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+func CreateStatusBar() *tview.TextView {
+	return tview.NewTextView().
+		SetTextAlign(tview.AlignLeft).
+		SetDynamicColors(true)
+}
+
+// UpdateStatusBar shows the connection state and stream health, plus the relay counters on a third line if relaying.
+// perFrame is how many packets arrived since the last redraw, uiDropped how many the dashboard itself was too slow
+// for, which is kept apart from the network loss.
+func UpdateStatusBar(bar *tview.TextView, status ingest.Status, source string, stats sequencing.Stats, perFrame, uiDropped uint64, relayStats []packethandling.RelayStats) {
+	var sb strings.Builder
+
+	stateColor := "green"
+	switch status.State {
+	case ingest.StatePaused, ingest.StateWaiting, ingest.StateIdle:
+		stateColor = "yellow"
+	case ingest.StateStalled, ingest.StateEnded:
+		stateColor = "red"
+	}
+	sb.WriteString(fmt.Sprintf("[%s]%s[-]", stateColor, tview.Escape(status.String())))
+	if status.Malformed > 0 {
+		sb.WriteString(fmt.Sprintf(" | %d malformed", status.Malformed))
+	}
+	if status.Errors > 0 {
+		sb.WriteString(fmt.Sprintf(" | %d read errors", status.Errors))
+	}
+	if status.LastError != nil && status.State != ingest.StateEnded {
+		sb.WriteString(fmt.Sprintf(" | last: %s", tview.Escape(status.LastError.Error())))
+	}
+
+	color := "green"
+	switch {
+	case stats.LossPercent > 5:
+		color = "red"
+	case stats.LossPercent > 1:
+		color = "yellow"
+	}
+	sb.WriteString(fmt.Sprintf("\n[%s]%s: %s[-] | %d/frame", color, tview.Escape(source), stats, perFrame))
+	if uiDropped > 0 {
+		sb.WriteString(fmt.Sprintf(" | [yellow]%d skipped by the UI[-]", uiDropped))
+	}
+
+	if len(relayStats) > 0 {
+		sb.WriteString("\nRelay:")
+		for _, r := range relayStats {
+			sb.WriteString(fmt.Sprintf(" %s (%s) %d sent", r.Address, r.Filter, r.Sent))
+			if r.Filtered > 0 {
+				sb.WriteString(fmt.Sprintf(" %d filtered", r.Filtered))
+			}
+			if r.Errors > 0 {
+				sb.WriteString(fmt.Sprintf(" [red]%d errors[-]", r.Errors))
+			}
+			sb.WriteString(" |")
+		}
+	}
+
+	bar.SetText(strings.TrimSuffix(sb.String(), " |"))
+}
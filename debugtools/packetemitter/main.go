@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/recording"
+	"forza-horizon-5-telemetry/shared/replay"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const progressInterval = time.Second
+
+func main() {
+	in := flag.String("in", "debugstream", "Recording or capture to play")
+	target := flag.String("target", fmt.Sprintf("%s:%d", packethandling.DefaultAddress, packethandling.DefaultPort), "Address to send the packets to, like the game's Data Out IP and port")
+	loops := flag.Int("loops", 1, "Times to play the recording, 0 loops until Ctrl-C")
+	speed := flag.Float64("speed", 1, "Playback speed, 0.25 to 8")
+	clockName := flag.String("clock", "auto", "What paces the packets: received (recorded receive times), timestamp (TimeStampMS) or auto")
+	packetSize := flag.Int("packetsize", 324, "Packet size for legacy recordings without a header")
+	jitter := flag.Duration("jitter", 0, "Delay each packet by a random amount up to this, packets can overtake each other like on Wi-Fi")
+	loss := flag.Float64("loss", 0, "Percentage of packets to drop")
+	seed := flag.Uint64("seed", 0, "Random seed for jitter and loss, 0 picks one")
+	flag.Parse()
+
+	clock, err := replay.ParseClock(*clockName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *loss < 0 || *loss > 100 {
+		log.Fatalf("-loss must be between 0 and 100, got %g", *loss)
+	}
+	if *seed == 0 {
+		*seed = uint64(time.Now().UnixNano())
+	}
+
+	conn, err := net.Dial("udp", *target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	e := &emitter{
+		conn:   conn,
+		jitter: *jitter,
+		loss:   *loss / 100,
+		rng:    rand.New(rand.NewPCG(*seed, *seed)),
+	}
+
+	log.Printf("Playing %s to %s at %gx (seed %d)\n", *in, *target, *speed, *seed)
+	for loop := 1; *loops == 0 || loop <= *loops; loop++ {
+		player, err := replay.OpenFile(*in, *packetSize, replay.Options{Clock: clock, Speed: *speed})
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = e.play(ctx, player, loop)
+		player.Close()
+		if err != nil {
+			break
+		}
+	}
+	e.wg.Wait() // Jittered packets still on their way
+
+	log.Printf("Sent %d packets, %d dropped, %d send errors\n", e.sent.Load(), e.dropped, e.errors.Load())
+}
+
+// emitter sends packets to the target with the jitter and loss asked for
+type emitter struct {
+	conn   net.Conn
+	jitter time.Duration
+	loss   float64
+	rng    *rand.Rand
+
+	wg      sync.WaitGroup
+	sent    atomic.Uint64
+	errors  atomic.Uint64
+	dropped uint64
+}
+
+// play sends every packet of one loop, returns an error if it should stop early
+func (e *emitter) play(ctx context.Context, player *replay.Player, loop int) error {
+	lastProgress := time.Now()
+	for {
+		pkt, err := player.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		var damage *recording.DamageError
+		if errors.As(err, &damage) {
+			log.Println(err)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				log.Println(err)
+			}
+			return err
+		}
+
+		if e.loss > 0 && e.rng.Float64() < e.loss {
+			e.dropped++
+		} else if e.jitter > 0 {
+			data := append([]byte(nil), pkt.Data...)
+			e.wg.Add(1)
+			time.AfterFunc(time.Duration(e.rng.Int64N(int64(e.jitter))), func() {
+				defer e.wg.Done()
+				e.send(data)
+			})
+		} else {
+			e.send(pkt.Data)
+		}
+
+		if now := time.Now(); now.Sub(lastProgress) >= progressInterval {
+			lastProgress = now
+			status := player.Status()
+			log.Printf("Loop %d | %s | %d sent, %d dropped\n", loop, status.Position.Truncate(time.Second), e.sent.Load(), e.dropped)
+		}
+	}
+}
+
+func (e *emitter) send(data []byte) {
+	// Nothing listening shows up as an error on the next write, which is normal for UDP
+	if _, err := e.conn.Write(data); err != nil {
+		e.errors.Add(1)
+		return
+	}
+	e.sent.Add(1)
+}
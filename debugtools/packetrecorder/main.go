@@ -1,63 +1,274 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/ingest"
 	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/recording"
+	"forza-horizon-5-telemetry/shared/sequencing"
+	"forza-horizon-5-telemetry/shared/source"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
+const progressInterval = time.Second
+
 func main() {
-	const (
-		packetsPerSecond = 60
-		secondsToRecord  = 10
-		packetSize       = 324
-		totalPackets     = packetsPerSecond * secondsToRecord
-	)
-
-	// Setup the UDP connection
-	conn, err := packethandling.Setup("127.0.0.1", 9999)
+	listenConfigs := packethandling.ListenFlags(flag.CommandLine)
+	sourceURI := flag.String("source", "", source.Usage+"\n(default: the UDP listeners)")
+	out := flag.String("out", "debugstream", "File to record to, with -split each session gets a numbered file e.g. debugstream-001")
+	duration := flag.Duration("duration", 0, "How long to record for, 0 records until Ctrl-C")
+	split := flag.Bool("split", false, "Start a new file for each race session (IsRaceOn), menu packets between sessions aren't kept")
+	splitGap := flag.Duration("splitgap", 5*time.Second, "How long IsRaceOn has to be off before a session counts as over")
+	notes := flag.String("notes", "", "Notes to store in the recording")
+	compress := flag.Bool("compress", true, "Gzip the recording, roughly half the size")
+	index := flag.Bool("index", true, "Write an index next to each recording (<file>.idx) for seeking by lap or time")
+	syncEvery := flag.Duration("sync", 2*time.Second, "How often to write the recording out to disk, a crash or power cut loses at most this much")
+	flag.Parse()
+
+	listeners, err := listenConfigs()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer conn.Close()
-	// Pre-allocate with exact size needed, but length 0
-	filebuf := make([]byte, 0, totalPackets*packetSize)
-	buf := make([]byte, packetSize) // Only need packetSize bytes
 
-	for i := 0; i < totalPackets; i++ {
-		n, _, err := conn.ReadFromUDP(buf)
-		if err != nil {
-			log.Fatal(err)
-		}
+	// Setup the packet source, normally the UDP connections
+	var src source.PacketSource
+	if *sourceURI != "" {
+		src, err = source.Open(*sourceURI)
+	} else {
+		src, err = source.NewUDP(listeners)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer src.Close()
 
-		if n != packetSize {
-			log.Printf("Warning: Packet %d: Expected %d bytes, got %d bytes\n", i, packetSize, n)
-			continue
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	r := &recorder{
+		out:      *out,
+		split:    *split,
+		splitGap: *splitGap,
+		source:   src.Name(),
+		notes:    *notes,
+		compress: *compress,
+		index:    *index,
+		sync:     *syncEvery,
+		tracker:  sequencing.NewTracker(sequencing.DefaultInterval),
+		session:  sequencing.NewTracker(sequencing.DefaultInterval),
+		monitor:  ingest.NewMonitor(0),
+		started:  time.Now(),
+	}
+
+	if *duration > 0 {
+		log.Printf("Recording from %s for %s, Ctrl-C to stop early\n", src.Name(), *duration)
+	} else {
+		log.Printf("Recording from %s, Ctrl-C to stop\n", src.Name())
+	}
+
+	err = ingest.Run(ctx, src, ingest.Config{
+		Monitor: r.monitor,
+		Packet:  r.packet,
+		Idle:    r.tick,
+	})
+	if err != nil {
+		log.Printf("Source finished: %v\n", err)
+	}
+
+	if err := r.closeSession(); err != nil {
+		log.Fatal(err)
+	}
+	if r.sessions == 0 {
+		log.Fatal("No packets recorded")
+	}
+
+	stats := r.tracker.Stats()
+	status := r.monitor.Status(time.Now())
+	log.Printf("Recorded %d packets in %d file(s), %d malformed skipped\n", r.total, r.sessions, status.Malformed)
+	log.Printf("Stream health: %s, %d lost, %d resets\n", stats, stats.Lost, stats.Resets)
+}
+
+// recorder writes packets to one file, or a file per session with -split.
+// Everything is called from the ingest loop so there's no locking.
+type recorder struct {
+	out      string
+	split    bool
+	splitGap time.Duration
+	source   string
+	notes    string
+	compress bool
+	index    bool
+	sync     time.Duration
+
+	tracker *sequencing.Tracker
+	session *sequencing.Tracker // Just the packets in the current file, for its trailer
+	monitor *ingest.Monitor
+	started time.Time
+
+	w        *recording.Writer
+	indexer  *recording.Indexer // nil without -index
+	path     string
+	sessions int
+	total    int
+	offSince time.Time // When IsRaceOn went off in the current session, zero while it's on
+
+	lastProgress time.Time
+	lastSync     time.Time
+}
+
+func (r *recorder) packet(pkt source.Packet, p *packethandling.ForzaHorizon5Packet, err error) {
+	defer r.tick()
+	if p == nil {
+		return
+	}
+
+	received := pkt.Received
+	if received.IsZero() {
+		received = time.Now()
+	}
+	r.tracker.Observe(p.TimeStampMS, received)
+
+	if r.split {
+		switch {
+		case p.IsRaceOn != 0:
+			r.offSince = time.Time{}
+		case r.w == nil:
+			return // Between sessions
+		case r.offSince.IsZero():
+			r.offSince = received
+		case received.Sub(r.offSince) > r.splitGap:
+			if err := r.closeSession(); err != nil {
+				log.Fatal(err)
+			}
+			return
 		}
+	}
 
-		var fh5Packet packethandling.ForzaHorizon5Packet
-		err = packethandling.ParsePacket(buf[:n], &fh5Packet)
-		if err != nil {
-			log.Printf("Warning: Packet %d: Parse error: %v\n", i, err)
-			continue
+	if r.w == nil {
+		if err := r.openSession(p, len(pkt.Data), received); err != nil {
+			log.Fatal(err)
 		}
+	}
 
-		filebuf = append(filebuf, buf[:n]...)
+	if r.indexer != nil {
+		r.indexer.Add(r.w.Offset(), received, pkt.Data)
+	}
+	r.session.Observe(p.TimeStampMS, received)
+	if err := r.w.WritePacket(pkt.Data, received); err != nil {
+		r.fatal(err)
+	}
+	r.total++
+}
 
-		if i%packetsPerSecond == 0 {
-			log.Printf("Recording: %d/%d seconds\n", i/packetsPerSecond, secondsToRecord)
-		}
+// openSession starts a file, the first packet gives the header its format and car
+func (r *recorder) openSession(p *packethandling.ForzaHorizon5Packet, size int, received time.Time) error {
+	r.sessions++
+	r.path = r.out
+	if r.split {
+		ext := filepath.Ext(r.out)
+		r.path = fmt.Sprintf("%s-%03d%s", strings.TrimSuffix(r.out, ext), r.sessions, ext)
 	}
 
-	expectedSize := totalPackets * packetSize
-	if len(filebuf) != expectedSize {
-		log.Printf("Warning: Expected file size %d, got %d\n", expectedSize, len(filebuf))
+	var flags recording.Flags
+	if r.compress {
+		flags |= recording.FlagGzip
 	}
 
-	err = os.WriteFile("./debugstream", filebuf, 0644)
+	w, err := recording.Create(r.path, recording.Header{
+		Format:     p.Format,
+		PacketSize: size,
+		Flags:      flags,
+		Metadata: recording.Metadata{
+			Start:      received,
+			CarOrdinal: p.Ordinal,
+			Source:     r.source,
+			Notes:      r.notes,
+		},
+	})
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	r.w = w
+	r.session.Reset()
+	r.lastSync = time.Now()
+	r.offSince = time.Time{}
+	if r.index {
+		r.indexer = &recording.Indexer{}
+	}
+
+	log.Printf("Recording %s packets to %s (car %d)\n", p.Format, r.path, p.Ordinal)
+	return nil
+}
+
+func (r *recorder) closeSession() error {
+	if r.w == nil {
+		return nil
+	}
+	count := r.w.Count()
+	r.w.SetHealth(r.session.Stats())
+	err := r.w.Close()
+	r.w = nil
+	if err != nil {
+		return err
+	}
+	log.Printf("Wrote %d packets to %s\n", count, r.path)
+
+	if r.indexer != nil {
+		ix := r.indexer.Index()
+		r.indexer = nil
+		if err := ix.Save(r.path); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// fatal exits after closing the current file, so whatever made it to disk
+// still gets its trailer and index
+func (r *recorder) fatal(err error) {
+	if cerr := r.closeSession(); cerr != nil {
+		log.Printf("Closing %s: %v\n", r.path, cerr)
+	}
+	log.Fatal(err)
+}
 
-	log.Printf("File written successfully: %d packets (%d bytes)\n", len(filebuf)/packetSize, len(filebuf))
+// tick syncs the recording to disk every -sync and shows the progress
+func (r *recorder) tick() {
+	now := time.Now()
+	if r.w != nil && r.sync > 0 && now.Sub(r.lastSync) >= r.sync {
+		r.lastSync = now
+		if err := r.w.Sync(); err != nil {
+			r.fatal(err)
+		}
+	}
+	r.progress()
+}
+
+// progress logs a status line every progressInterval
+func (r *recorder) progress() {
+	now := time.Now()
+	if now.Sub(r.lastProgress) < progressInterval {
+		return
+	}
+	r.lastProgress = now
+
+	file := "not recording"
+	if r.w != nil {
+		file = fmt.Sprintf("%s %d packets", r.path, r.w.Count())
+	}
+	log.Printf("%s | %s | %s | %s\n",
+		now.Sub(r.started).Truncate(time.Second), r.monitor.Status(now), file, r.tracker.Stats())
 }
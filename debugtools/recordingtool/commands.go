@@ -0,0 +1,407 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/recording"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+func runInfo(args []string) error {
+	fs, o := newFlags("info")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := needArgs(fs, 1); err != nil {
+		return err
+	}
+
+	for _, path := range fs.Args() {
+		in, err := openInput(path, o.packetSize)
+		if err != nil {
+			return err
+		}
+		var (
+			count, racing uint64
+			last          time.Duration
+			x             recording.Indexer
+		)
+		err = in.each(func(p *packet) error {
+			count++
+			if p.Packet.IsRaceOn != 0 {
+				racing++
+			}
+			last = p.Elapsed
+			x.Add(0, p.Received, p.Data)
+			return nil
+		})
+		in.Close()
+		if err != nil {
+			return err
+		}
+
+		h := in.Header()
+		fmt.Println(path)
+		if h.Version == 0 {
+			fmt.Printf("  legacy recording, %s packets of %d bytes\n", h.Format, h.PacketSize)
+		} else {
+			fmt.Printf("  version %d, %s packets of %d bytes, gzip %t\n", h.Version, h.Format, h.PacketSize, h.Flags&recording.FlagGzip != 0)
+			if !h.Metadata.Start.IsZero() {
+				fmt.Printf("  started %s\n", h.Metadata.Start.Local().Format(time.DateTime))
+			}
+			fmt.Printf("  car %d", h.Metadata.CarOrdinal)
+			if h.Metadata.Source != "" {
+				fmt.Printf(", from %s", h.Metadata.Source)
+			}
+			fmt.Println()
+			if h.Metadata.Notes != "" {
+				fmt.Printf("  notes %q\n", h.Metadata.Notes)
+			}
+		}
+		fmt.Printf("  %d packets (%d racing) over %s\n", count, racing, last.Truncate(time.Millisecond))
+		if stretches, bytes := in.r.Skipped(); stretches > 0 {
+			fmt.Printf("  %d damaged stretch(es) skipped, %d bytes\n", stretches, bytes)
+		}
+		if t, ok := in.r.Trailer(); !ok && h.Version != 0 {
+			fmt.Println("  no trailer, it was cut off while recording (recover fixes it)")
+		} else if t.Health != nil {
+			fmt.Printf("  stream health while recording: %s, %d lost, %d resets\n", t.Health, t.Health.Lost, t.Health.Resets)
+		}
+
+		for _, e := range x.Index().Entries {
+			if e.Event != recording.EventCheckpoint {
+				fmt.Printf("  %10s  packet %-7d %-10s lap %d\n", e.Elapsed.Truncate(time.Millisecond), e.Record, e.Event, e.Lap)
+			}
+		}
+	}
+	return nil
+}
+
+func runTrim(args []string) error {
+	fs, o := newFlags("trim")
+	inPath := fs.String("in", "", "Recording to trim")
+	outPath := fs.String("out", "", "File to write")
+	from := fs.Duration("from", 0, "Time into the recording to start at, as shown by a replay")
+	to := fs.Duration("to", 0, "Time into the recording to stop at, 0 is the end")
+	first := fs.Int64("first", -1, "First packet to keep, numbered from 0")
+	last := fs.Int64("last", -1, "Last packet to keep")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	byPacket := *first >= 0 || *last >= 0
+	switch {
+	case byPacket && (*from != 0 || *to != 0):
+		return errors.New("trim by time (-from/-to) or by packet (-first/-last), not both")
+	case *to != 0 && *to < *from:
+		return fmt.Errorf("-to %s is before -from %s", *to, *from)
+	case *last >= 0 && *last < *first:
+		return fmt.Errorf("-last %d is before -first %d", *last, *first)
+	}
+
+	return edit(*inPath, *outPath, o, func(p *packet, dst *output) error {
+		if byPacket {
+			switch {
+			case *last >= 0 && int64(p.Number) > *last:
+				return errStop
+			case int64(p.Number) < *first:
+				return nil
+			}
+		} else {
+			switch {
+			case *to != 0 && p.Elapsed > *to:
+				return errStop
+			case p.Elapsed < *from:
+				return nil
+			}
+		}
+		return dst.write(p)
+	})
+}
+
+func runFilter(args []string) error {
+	fs, o := newFlags("filter")
+	inPath := fs.String("in", "", "Recording to filter")
+	outPath := fs.String("out", "", "File to write")
+	raceOn := fs.Bool("raceon", false, "Only keep packets with IsRaceOn set, dropping menus and pauses")
+	car := fs.Int("car", 0, "Only keep packets from this car ordinal")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*raceOn && *car == 0 {
+		return errors.New("filter needs -raceon or -car")
+	}
+
+	return edit(*inPath, *outPath, o, func(p *packet, dst *output) error {
+		if *raceOn && p.Packet.IsRaceOn == 0 {
+			return nil
+		}
+		if *car != 0 && p.Packet.Ordinal != int32(*car) {
+			return nil
+		}
+		return dst.write(p)
+	})
+}
+
+func runSplit(args []string) error {
+	fs, o := newFlags("split")
+	inPath := fs.String("in", "", "Recording to split")
+	outPath := fs.String("out", "", "Base name for the files, numbered like packetrecorder -split (default: -in)")
+	by := fs.String("by", "race", "What each file holds: race or lap")
+	gap := fs.Duration("gap", 5*time.Second, "How long IsRaceOn has to be off before a race counts as over")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inPath == "" {
+		return errors.New("split needs -in")
+	}
+	if *by != "race" && *by != "lap" {
+		return fmt.Errorf("unknown -by %q, expected race or lap", *by)
+	}
+	byLap := *by == "lap"
+	if *outPath == "" {
+		*outPath = *inPath
+	}
+
+	in, err := openInput(*inPath, o.packetSize)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var (
+		cur      *output
+		files    int
+		lap      uint16
+		offSince time.Duration = -1 // When IsRaceOn went off in the current race
+	)
+	next := func() error {
+		if cur == nil {
+			return nil
+		}
+		_, err := cur.close()
+		cur = nil
+		return err
+	}
+
+	// Like packetrecorder -split a race lasts until IsRaceOn has been off for
+	// -gap, pauses in between stay in it and menus between races are dropped
+	err = in.each(func(p *packet) error {
+		racing := p.Packet.IsRaceOn != 0
+		switch {
+		case racing:
+			offSince = -1
+		case cur == nil:
+			return nil
+		case offSince < 0:
+			offSince = p.Elapsed
+		case p.Elapsed-offSince > *gap:
+			return next()
+		}
+
+		if byLap {
+			if !p.Packet.Format.HasDash() {
+				return fmt.Errorf("%s: %s packets have no lap number", *inPath, p.Packet.Format)
+			}
+			if cur != nil && racing && p.Packet.LapNumber != lap {
+				if err := next(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if cur == nil {
+			files++
+			lap = p.Packet.LapNumber
+			cur = newOutput(numbered(*outPath, files), in.Header(), o)
+		}
+		return cur.write(p)
+	})
+	if err != nil {
+		if cur != nil {
+			cur.abort()
+		}
+		return err
+	}
+	err = next()
+	if err == nil && files == 0 {
+		err = fmt.Errorf("%s: no races in it", *inPath)
+	}
+	return err
+}
+
+func runConcat(args []string) error {
+	fs, o := newFlags("concat")
+	outPath := fs.String("out", "", "File to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := needArgs(fs, 2); err != nil {
+		return err
+	}
+	if err := checkOut(*outPath, fs.Args()...); err != nil {
+		return err
+	}
+
+	// Every file is opened first so a bad one fails before anything's written
+	var inputs []*input
+	defer func() {
+		for _, in := range inputs {
+			in.Close()
+		}
+	}()
+	var notes []string
+	for _, path := range fs.Args() {
+		in, err := openInput(path, o.packetSize)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, in)
+		if n := in.Header().Metadata.Notes; n != "" && !slices.Contains(notes, n) {
+			notes = append(notes, n)
+		}
+	}
+
+	header := inputs[0].Header()
+	header.Metadata.Notes = strings.Join(notes, "; ")
+	dst := newOutput(*outPath, header, o)
+
+	var (
+		last time.Time
+		err  error
+	)
+	for _, in := range inputs {
+		if f := in.Header().Format; f != header.Format {
+			log.Printf("%s has %s packets, %s has %s\n", in.path, f, inputs[0].path, header.Format)
+		}
+		first := true
+		err = in.each(func(p *packet) error {
+			if first && !p.Received.IsZero() && p.Received.Before(last) {
+				log.Printf("%s starts before the recording in front of it ends, its times are kept as they are\n", in.path)
+			}
+			first = false
+			if !p.Received.IsZero() {
+				last = p.Received
+			}
+			return dst.write(p)
+		})
+		if err != nil {
+			dst.abort()
+			return err
+		}
+	}
+	_, err = dst.close()
+	return err
+}
+
+func runRecover(args []string) error {
+	fs, o := newFlags("recover")
+	inPath := fs.String("in", "", "Recording that was cut off or damaged")
+	outPath := fs.String("out", "", "File to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *inPath == "" || *outPath == "" {
+		return errors.New("needs -in and -out")
+	}
+	in, err := openInput(*inPath, o.packetSize)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// Everything readable is kept, damage is logged by each as it's skipped
+	err = copyTo(in, *outPath, o, func(p *packet, dst *output) error {
+		return dst.write(p)
+	})
+	if err != nil {
+		return err
+	}
+
+	stretches, bytes := in.r.Skipped()
+	_, complete := in.r.Trailer()
+	switch {
+	case stretches > 0:
+		log.Printf("Skipped %d damaged stretch(es), %d bytes\n", stretches, bytes)
+	case complete:
+		log.Printf("%s was already complete\n", *inPath)
+	default:
+		log.Printf("%s was cut off after its last packet, nothing was lost\n", *inPath)
+	}
+	return nil
+}
+
+func runIndex(args []string) error {
+	fs, o := newFlags("index")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := needArgs(fs, 1); err != nil {
+		return err
+	}
+
+	for _, path := range fs.Args() {
+		ix, err := recording.BuildIndex(path, o.packetSize)
+		if err != nil {
+			return err
+		}
+		if err := ix.Save(path); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d entries, %d laps, %d races\n", recording.IndexPath(path),
+			len(ix.Entries), len(ix.Events(recording.EventLap)), len(ix.Events(recording.EventRaceStart)))
+	}
+	return nil
+}
+
+// edit copies the packets fn writes from one recording to a new one
+func edit(inPath, outPath string, o *options, fn func(p *packet, dst *output) error) error {
+	if inPath == "" || outPath == "" {
+		return errors.New("needs -in and -out")
+	}
+	in, err := openInput(inPath, o.packetSize)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return copyTo(in, outPath, o, fn)
+}
+
+// copyTo writes the packets fn picks from in to a new recording
+func copyTo(in *input, outPath string, o *options, fn func(p *packet, dst *output) error) error {
+	if err := checkOut(outPath, in.path); err != nil {
+		return err
+	}
+	dst := newOutput(outPath, in.Header(), o)
+	if err := in.each(func(p *packet) error { return fn(p, dst) }); err != nil {
+		dst.abort()
+		return err
+	}
+	count, err := dst.close()
+	if err == nil && count == 0 {
+		err = fmt.Errorf("nothing in %s matched, %s not written", in.path, outPath)
+	}
+	return err
+}
+
+// checkOut stops an output overwriting one of the inputs while it's being read
+func checkOut(outPath string, inPaths ...string) error {
+	if outPath == "" {
+		return errors.New("needs -out")
+	}
+	out, err := filepath.Abs(outPath)
+	if err != nil {
+		return err
+	}
+	for _, path := range inPaths {
+		if in, err := filepath.Abs(path); err == nil && in == out {
+			return fmt.Errorf("-out %s is one of the inputs", outPath)
+		}
+	}
+	return nil
+}
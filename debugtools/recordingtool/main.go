@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/recording"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"info", "<file>...", "Show a recording's header, length, races and laps", runInfo},
+	{"trim", "-in <file> -out <file> [-from 1m -to 1m30s | -first 100 -last 500]", "Keep a time or packet range", runTrim},
+	{"split", "-in <file> [-out <file>] [-by race|lap]", "Write each race or lap to its own numbered file", runSplit},
+	{"concat", "-out <file> <file> <file>...", "Join recordings end to end", runConcat},
+	{"filter", "-in <file> -out <file> [-raceon] [-car <ordinal>]", "Keep only racing packets or one car's", runFilter},
+	{"recover", "-in <file> -out <file>", "Rebuild a recording that was cut off or damaged into a complete one", runRecover},
+	{"index", "<file>...", "Rebuild recording indexes", runIndex},
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					os.Exit(2)
+				}
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: recordingtool <command> [flags], -h after a command for its flags")
+	fmt.Fprintln(os.Stderr)
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n           %s\n", c.name, c.args, c.summary)
+	}
+}
+
+// options are the flags every editing command has
+type options struct {
+	packetSize int
+	compress   bool
+	index      bool
+}
+
+func newFlags(name string) (*flag.FlagSet, *options) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	o := &options{}
+	fs.IntVar(&o.packetSize, "packetsize", 324, "Packet size for legacy recordings without a header")
+	fs.BoolVar(&o.compress, "compress", true, "Gzip the output")
+	fs.BoolVar(&o.index, "index", true, "Write an index next to each output (<file>.idx)")
+	return fs, o
+}
+
+// packet is a packet read from the input with where it sits in the recording
+type packet struct {
+	recording.Record
+	Number  uint64        // Packet number in the input, from 0
+	Elapsed time.Duration // Time into the input, the same as a replay shows
+	Packet  *packethandling.ForzaHorizon5Packet
+}
+
+// errStop ends a read early without it being an error
+var errStop = errors.New("stop")
+
+// input is a recording being read packet by packet
+type input struct {
+	path string
+	r    *recording.Reader
+}
+
+func openInput(path string, legacySize int) (*input, error) {
+	r, err := recording.Open(path, legacySize)
+	if err != nil {
+		return nil, err
+	}
+	return &input{path: path, r: r}, nil
+}
+
+func (in *input) Header() recording.Header {
+	return in.r.Header()
+}
+
+func (in *input) Close() error {
+	return in.r.Close()
+}
+
+// each calls fn for every packet until the end or fn returns errStop. Damage
+// the reader can get past is logged and skipped, anything else that isn't a
+// whole Forza packet is an error so nothing edited can have bad packets in it.
+func (in *input) each(fn func(p *packet) error) error {
+	var (
+		timeline recording.Timeline
+		parsed   packethandling.ForzaHorizon5Packet
+		p        = packet{Packet: &parsed}
+		number   uint64
+	)
+	for {
+		rec, err := in.r.NextPacket()
+		var damage *recording.DamageError
+		switch {
+		case err == io.EOF:
+			return nil
+		case errors.As(err, &damage):
+			log.Printf("%s: %v\n", in.path, err)
+			continue
+		case err != nil:
+			return fmt.Errorf("%s: packet %d: %w", in.path, number, err)
+		}
+		if err := packethandling.ParsePacket(rec.Data, &parsed); err != nil {
+			return fmt.Errorf("%s: packet %d: %w", in.path, number, err)
+		}
+
+		p.Record = rec
+		p.Number = number
+		number++
+		p.Elapsed = timeline.Advance(rec.Received, parsed.TimeStampMS)
+		if err := fn(&p); err == errStop {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// output is a recording being written. The file is only created once there's
+// a packet for it, with the input's metadata but the first packet's time and car.
+type output struct {
+	path   string
+	header recording.Header
+	opts   *options
+
+	w  *recording.Writer
+	ix *recording.Indexer
+}
+
+func newOutput(path string, in recording.Header, opts *options) *output {
+	return &output{path: path, header: in, opts: opts}
+}
+
+func (o *output) write(p *packet) error {
+	if o.w == nil {
+		h := recording.Header{
+			Format:     p.Packet.Format,
+			PacketSize: len(p.Data),
+			Metadata:   o.header.Metadata,
+		}
+		h.Metadata.CarOrdinal = p.Packet.Ordinal
+		if !p.Received.IsZero() {
+			h.Metadata.Start = p.Received
+		}
+		if o.opts.compress {
+			h.Flags |= recording.FlagGzip
+		}
+		w, err := recording.Create(o.path, h)
+		if err != nil {
+			return err
+		}
+		o.w = w
+		if o.opts.index {
+			o.ix = &recording.Indexer{}
+		}
+	}
+
+	if o.ix != nil {
+		o.ix.Add(o.w.Offset(), p.Received, p.Data)
+	}
+	return o.w.WritePacket(p.Data, p.Received)
+}
+
+// close finishes the file and its index, returning how many packets it got
+func (o *output) close() (int, error) {
+	if o.w == nil {
+		return 0, nil
+	}
+	count := o.w.Count()
+	err := o.w.Close()
+	o.w = nil
+	if err == nil && o.ix != nil {
+		err = o.ix.Index().Save(o.path)
+	}
+	if err != nil {
+		return count, err
+	}
+	log.Printf("Wrote %d packets to %s\n", count, o.path)
+	return count, nil
+}
+
+// abort closes and deletes a file that failed part way through
+func (o *output) abort() {
+	if o.w != nil {
+		o.w.Close()
+		o.w = nil
+		os.Remove(o.path)
+	}
+}
+
+// numbered returns path with -001, -002... before the extension like packetrecorder -split
+func numbered(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%03d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// needArgs checks a command was given at least n file arguments
+func needArgs(fs *flag.FlagSet, n int) error {
+	if fs.NArg() < n {
+		return fmt.Errorf("%s needs at least %d file(s), -h for help", fs.Name(), n)
+	}
+	return nil
+}
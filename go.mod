@@ -3,12 +3,14 @@ module forza-horizon-5-telemetry
 go 1.24.3
 
 require (
-	github.com/davecgh/go-spew v1.1.1
+	github.com/gdamore/tcell/v2 v2.8.1
+	github.com/rivo/tview v0.0.0-20250501113434-0c592cd31026
+)
+
+require (
 	github.com/gdamore/encoding v1.0.1 // indirect
-	github.com/gdamore/tcell/v2 v2.8.1 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
 	github.com/mattn/go-runewidth v0.0.16 // indirect
-	github.com/rivo/tview v0.0.0-20250501113434-0c592cd31026
 	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/term v0.28.0 // indirect
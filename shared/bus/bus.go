@@ -0,0 +1,212 @@
+// Package bus hands every packet from the read loop to any number of
+// consumers, each picking what happens when it can't keep up.
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/source"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is returned by Receive once the subscription or bus is closed
+var ErrClosed = errors.New("bus: subscription closed")
+
+// DefaultQueueSize is the queue length for PolicyQueue when none is given, about a second of packets
+const DefaultQueueSize = 64
+
+// Policy is what a subscription does when it falls behind the publisher
+type Policy int
+
+const (
+	PolicyEvery  Policy = iota // Every packet, the publisher waits for the subscriber
+	PolicyLatest               // Only the newest packet, older unread ones are dropped
+	PolicyQueue                // Bounded queue, the oldest packet is dropped when it's full
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyEvery:
+		return "every"
+	case PolicyLatest:
+		return "latest"
+	case PolicyQueue:
+		return "queue"
+	default:
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+}
+
+// Message is one packet as published
+type Message struct {
+	// Raw is the datagram as received, shared between subscribers so don't modify it
+	Raw []byte
+	source.Metadata
+
+	// Packet is the parsed packet, only set if Err is nil
+	Packet packethandling.ForzaHorizon5Packet
+	Err    error // Why Raw didn't parse
+}
+
+// Bus broadcasts messages to its subscribers
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func New() *Bus {
+	return &Bus{subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe adds a subscriber, size is the queue length for PolicyQueue (<= 0 uses DefaultQueueSize)
+func (b *Bus) Subscribe(policy Policy, size int) *Subscription {
+	switch policy {
+	case PolicyEvery:
+		size = 0
+	case PolicyLatest:
+		size = 1
+	default:
+		if size <= 0 {
+			size = DefaultQueueSize
+		}
+	}
+
+	s := &Subscription{bus: b, policy: policy, ch: make(chan Message, size), done: make(chan struct{})}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		s.close()
+		return s
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Publish sends m to every subscriber. It only blocks on PolicyEvery subscribers.
+// Raw is copied, so the caller can reuse it straight away.
+func (b *Bus) Publish(m Message) {
+	m.Raw = append([]byte(nil), m.Raw...)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		s.deliver(m)
+	}
+}
+
+// PublishPacket is Publish in the shape of ingest.Config.Packet
+func (b *Bus) PublishPacket(pkt source.Packet, p *packethandling.ForzaHorizon5Packet, err error) {
+	m := Message{Raw: pkt.Data, Metadata: pkt.Metadata, Err: err}
+	if p != nil {
+		m.Packet = *p
+	}
+	b.Publish(m)
+}
+
+// Close closes every subscription, nothing can be published after
+func (b *Bus) Close() {
+	b.mu.Lock()
+	b.closed = true
+	subs := make([]*Subscription, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+}
+
+// Subscription is one consumer's feed
+type Subscription struct {
+	bus       *Bus
+	policy    Policy
+	ch        chan Message
+	done      chan struct{}
+	closeOnce sync.Once
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// C returns the channel messages arrive on, it's closed on Unsubscribe
+func (s *Subscription) C() <-chan Message {
+	return s.ch
+}
+
+// Receive waits for the next message, returns ErrClosed once unsubscribed
+func (s *Subscription) Receive(ctx context.Context) (Message, error) {
+	select {
+	case m, ok := <-s.ch:
+		if !ok {
+			return Message{}, ErrClosed
+		}
+		return m, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+func (s *Subscription) Policy() Policy {
+	return s.policy
+}
+
+// Delivered is how many messages have been handed to the subscription
+func (s *Subscription) Delivered() uint64 {
+	return s.delivered.Load()
+}
+
+// Dropped is how many messages were thrown away because the subscriber fell behind
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe stops delivery and closes the channel
+func (s *Subscription) Unsubscribe() {
+	// Signal first, a publisher blocked on a PolicyEvery send is holding the bus lock
+	s.closeOnce.Do(func() { close(s.done) })
+
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	}
+}
+
+func (s *Subscription) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+	close(s.ch)
+}
+
+// deliver is called with the bus lock held, so only one publisher touches ch at a time
+func (s *Subscription) deliver(m Message) {
+	if s.policy == PolicyEvery {
+		select {
+		case s.ch <- m:
+			s.delivered.Add(1)
+		case <-s.done:
+		}
+		return
+	}
+
+	for {
+		select {
+		case s.ch <- m:
+			s.delivered.Add(1)
+			return
+		default:
+		}
+		// Full, make room by dropping the oldest
+		select {
+		case <-s.ch:
+			s.dropped.Add(1)
+		default:
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/source"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	publishers   = 4
+	perPublisher = 2000
+)
+
+// message numbers a message by publisher and sequence so the receiver can check them
+func message(publisher int, seq uint32) Message {
+	m := Message{Raw: []byte{byte(publisher)}, Metadata: source.Metadata{Source: fmt.Sprint(publisher)}}
+	m.Packet.TimeStampMS = seq
+	return m
+}
+
+// publishAll runs the publishers concurrently and closes the bus when they're done
+func publishAll(b *Bus) {
+	var wg sync.WaitGroup
+	for p := range publishers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seq := range uint32(perPublisher) {
+				b.Publish(message(p, seq+1))
+			}
+		}()
+	}
+	wg.Wait()
+	b.Close()
+}
+
+// drain reads a subscription until it's closed, checking each publisher's
+// messages arrive in order, and returns how many it got from each
+func drain(t *testing.T, s *Subscription, slow bool) [publishers]int {
+	t.Helper()
+	var got [publishers]int
+	var last [publishers]uint32
+	for m := range s.C() {
+		p := int(m.Raw[0])
+		if m.Source != fmt.Sprint(p) {
+			t.Errorf("message from publisher %d has source %q", p, m.Source)
+		}
+		if m.Packet.TimeStampMS <= last[p] {
+			t.Errorf("publisher %d: message %d after %d", p, m.Packet.TimeStampMS, last[p])
+		}
+		last[p] = m.Packet.TimeStampMS
+		got[p]++
+		if slow && got[p]%100 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return got
+}
+
+// Every subscriber gets every message, however many publishers and subscribers there are
+func TestEvery(t *testing.T) {
+	b := New()
+	subs := make([]*Subscription, 3)
+	for i := range subs {
+		subs[i] = b.Subscribe(PolicyEvery, 0)
+	}
+
+	results := make([][publishers]int, len(subs))
+	var wg sync.WaitGroup
+	for i, s := range subs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = drain(t, s, i == 0)
+		}()
+	}
+	publishAll(b)
+	wg.Wait()
+
+	for i, got := range results {
+		for p, n := range got {
+			if n != perPublisher {
+				t.Errorf("subscriber %d got %d messages from publisher %d, want %d", i, n, p, perPublisher)
+			}
+		}
+		if subs[i].Dropped() != 0 || subs[i].Delivered() != publishers*perPublisher {
+			t.Errorf("subscriber %d: %d delivered %d dropped", i, subs[i].Delivered(), subs[i].Dropped())
+		}
+	}
+}
+
+// Lossy subscribers drop instead of holding the publishers up, and everything
+// published is either received or counted as dropped
+func TestLossy(t *testing.T) {
+	for _, policy := range []Policy{PolicyLatest, PolicyQueue} {
+		t.Run(policy.String(), func(t *testing.T) {
+			b := New()
+			fast := b.Subscribe(policy, 16)
+			slow := b.Subscribe(policy, 16)
+			every := b.Subscribe(PolicyEvery, 0)
+
+			var fastGot, slowGot [publishers]int
+			var wg sync.WaitGroup
+			wg.Add(3)
+			go func() { defer wg.Done(); fastGot = drain(t, fast, false) }()
+			go func() { defer wg.Done(); slowGot = drain(t, slow, true) }()
+			go func() { defer wg.Done(); drain(t, every, false) }()
+			publishAll(b)
+			wg.Wait()
+
+			for _, c := range []struct {
+				name string
+				s    *Subscription
+				got  [publishers]int
+			}{{"fast", fast, fastGot}, {"slow", slow, slowGot}} {
+				received := 0
+				for _, n := range c.got {
+					received += n
+				}
+				if c.s.Delivered() != publishers*perPublisher {
+					t.Errorf("%s: %d delivered, want %d", c.name, c.s.Delivered(), publishers*perPublisher)
+				}
+				if uint64(received)+c.s.Dropped() != c.s.Delivered() {
+					t.Errorf("%s: %d received + %d dropped != %d delivered", c.name, received, c.s.Dropped(), c.s.Delivered())
+				}
+			}
+			if slow.Dropped() == 0 {
+				t.Error("the slow subscriber didn't drop anything")
+			}
+		})
+	}
+}
+
+// With nobody reading, a queue keeps the newest messages and Latest just the last
+func TestKeepsNewest(t *testing.T) {
+	for _, tt := range []struct {
+		policy Policy
+		keep   int
+	}{{PolicyQueue, 8}, {PolicyLatest, 1}} {
+		b := New()
+		s := b.Subscribe(tt.policy, 8)
+		for seq := range uint32(100) {
+			b.Publish(message(0, seq))
+		}
+		b.Close()
+
+		var got []uint32
+		for m := range s.C() {
+			got = append(got, m.Packet.TimeStampMS)
+		}
+		if len(got) != tt.keep || got[0] != uint32(100-tt.keep) || got[len(got)-1] != 99 {
+			t.Errorf("%s: got %v, want the last %d", tt.policy, got, tt.keep)
+		}
+		if s.Dropped() != uint64(100-tt.keep) {
+			t.Errorf("%s: %d dropped, want %d", tt.policy, s.Dropped(), 100-tt.keep)
+		}
+	}
+}
+
+// A publisher stuck on a PolicyEvery subscriber that's gone away gets going again
+func TestUnsubscribeUnblocksPublisher(t *testing.T) {
+	b := New()
+	stuck := b.Subscribe(PolicyEvery, 0)
+	other := b.Subscribe(PolicyQueue, 4)
+
+	published := make(chan struct{})
+	go func() {
+		b.Publish(message(0, 1))
+		close(published)
+	}()
+
+	// Nothing reads stuck, so the publisher waits until it's unsubscribed
+	select {
+	case <-published:
+		t.Fatal("Publish didn't wait for the PolicyEvery subscriber")
+	case <-time.After(20 * time.Millisecond):
+	}
+	stuck.Unsubscribe()
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish still blocked after Unsubscribe")
+	}
+
+	if _, ok := <-stuck.C(); ok {
+		t.Error("unsubscribed channel still open")
+	}
+	if m := <-other.C(); m.Packet.TimeStampMS != 1 {
+		t.Errorf("other subscriber got %v", m.Packet.TimeStampMS)
+	}
+	stuck.Unsubscribe() // Twice is fine
+	b.Close()
+}
+
+func TestReceive(t *testing.T) {
+	b := New()
+	s := b.Subscribe(PolicyQueue, 4)
+
+	raw := []byte{0, 1, 2}
+	b.Publish(Message{Raw: raw})
+	raw[0] = 9 // Publish copied it
+	m, err := s.Receive(context.Background())
+	if err != nil || m.Raw[0] != 0 {
+		t.Fatalf("got %v, %v", m.Raw, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.Receive(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v with nothing published, want the context's error", err)
+	}
+
+	b.Close()
+	if _, err := s.Receive(context.Background()); err != ErrClosed {
+		t.Fatalf("got %v after Close, want ErrClosed", err)
+	}
+	if _, ok := <-b.Subscribe(PolicyEvery, 0).C(); ok {
+		t.Fatal("subscribing to a closed bus gave an open channel")
+	}
+}
+
+// Subscribing and unsubscribing while publishing is going on is safe
+func TestSubscribeWhilePublishing(t *testing.T) {
+	b := New()
+	done := make(chan struct{})
+	go func() {
+		publishAll(b)
+		close(done)
+	}()
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				s := b.Subscribe(Policy(i%3), 4)
+				select {
+				case <-s.C():
+				case <-done:
+				}
+				s.Unsubscribe()
+			}
+		}()
+	}
+	wg.Wait()
+	<-done
+}
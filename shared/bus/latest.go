@@ -0,0 +1,33 @@
+package bus
+
+import "sync/atomic"
+
+// Latest holds the newest value for readers that only care about now, like a
+// renderer running on its own clock. Store and Load never block each other.
+// There should only be one writer.
+type Latest[T any] struct {
+	v     atomic.Pointer[latestEntry[T]]
+	count atomic.Uint64
+}
+
+type latestEntry[T any] struct {
+	value T
+	seq   uint64
+}
+
+// Store replaces the value. The old one is left alone, so readers holding it are safe.
+func (l *Latest[T]) Store(v T) {
+	seq := l.count.Add(1)
+	l.v.Store(&latestEntry[T]{value: v, seq: seq})
+}
+
+// Load returns the newest value and how many have been stored in total, ok is
+// false until the first Store. Comparing seq between calls gives the number of
+// values stored in between.
+func (l *Latest[T]) Load() (v T, seq uint64, ok bool) {
+	e := l.v.Load()
+	if e == nil {
+		return v, 0, false
+	}
+	return e.value, e.seq, true
+}
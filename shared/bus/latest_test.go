@@ -0,0 +1,73 @@
+package bus
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLatestEmpty(t *testing.T) {
+	var l Latest[int]
+	if v, seq, ok := l.Load(); ok || v != 0 || seq != 0 {
+		t.Fatalf("got %d, %d, %t before any Store", v, seq, ok)
+	}
+	l.Store(5)
+	l.Store(7)
+	if v, seq, ok := l.Load(); !ok || v != 7 || seq != 2 {
+		t.Fatalf("got %d, %d, %t, want 7, 2, true", v, seq, ok)
+	}
+}
+
+// Readers running alongside the writer always see a value that matches its
+// sequence number, and never go backwards
+func TestLatestConcurrent(t *testing.T) {
+	type value struct {
+		n    uint64
+		data [8]uint64 // Big enough that a torn read would show
+	}
+	const stores = 20000
+
+	var l Latest[value]
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var last uint64
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				v, seq, ok := l.Load()
+				if !ok {
+					continue
+				}
+				if v.n != seq || v.data[7] != seq {
+					t.Errorf("value %d / %d stored as %d", v.n, v.data[7], seq)
+					return
+				}
+				if seq < last {
+					t.Errorf("seq went back from %d to %d", last, seq)
+					return
+				}
+				last = seq
+			}
+		}()
+	}
+
+	for i := range uint64(stores) {
+		v := value{n: i + 1}
+		for j := range v.data {
+			v.data[j] = i + 1
+		}
+		l.Store(v)
+	}
+	close(done)
+	wg.Wait()
+
+	if v, seq, _ := l.Load(); seq != stores || v.n != stores {
+		t.Fatalf("ended on %d / %d, want %d", v.n, seq, stores)
+	}
+}
@@ -0,0 +1,146 @@
+// Package dynamics turns the raw motion channels into the ones drivers care
+// about (G forces, slip angle, wheel spin...).
+//
+// Forza already sends acceleration, velocity and angular velocity in the car's
+// own frame (X = right, Y = up, Z = forward), so the G channels come straight
+// off those. Position and orientation are world space, use Pose to move
+// between the two.
+package dynamics
+
+import (
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"math"
+)
+
+const (
+	StandardGravity = 9.80665 // m/s²
+
+	// Below this the slip angle is just noise from a car that's barely moving
+	minSlipAngleSpeed = 1.0 // m/s
+
+	// Tire radius estimates need some speed and grip to be any good. Forza's
+	// slip ratio is normalized, anything past 1 has lost grip.
+	minRadiusSpeed    = 10.0 // m/s
+	maxRadiusSlip     = 0.1
+	minRadiusWheels   = 2
+	minWheelRotations = 1.0 // rad/s
+
+	// How far each good estimate moves TireRadius, small enough that a few
+	// packets with the wheels locked or spinning barely show
+	radiusSmoothing = 0.05
+)
+
+// Channels are the derived values for one packet
+type Channels struct {
+	LateralG      float64 // positive when accelerating to the right
+	LongitudinalG float64 // positive under acceleration, negative under braking
+	VerticalG     float64 // positive when pushed up, e.g. landing a jump
+
+	SlipAngle float64 // body slip angle in radians, positive when sliding to the right
+	YawRate   float64 // rad/s about the car's up axis
+
+	SpeedOverGround float64    // m/s, from the velocity in the car's horizontal plane
+	WheelSpeeds     [4]float64 // m/s surface speed of each wheel (FL, FR, RL, RR), 0 without a tire radius
+	WheelSpeed      float64    // m/s average surface speed of the driven wheels, 0 without a tire radius
+}
+
+// Derive works out the derived channels for a packet. tireRadius is in meters,
+// pass 0 if it's not known and the wheel speeds are left at zero (see EstimateTireRadius).
+func Derive(p *packethandling.ForzaHorizon5Packet, tireRadius float64) Channels {
+	var c Channels
+
+	c.LateralG = float64(p.AccelerationX) / StandardGravity
+	c.LongitudinalG = float64(p.AccelerationZ) / StandardGravity
+	c.VerticalG = float64(p.AccelerationY) / StandardGravity
+
+	c.YawRate = float64(p.AngularVelocityY)
+
+	vx, vz := float64(p.VelocityX), float64(p.VelocityZ)
+	c.SpeedOverGround = math.Hypot(vx, vz)
+	if c.SpeedOverGround >= minSlipAngleSpeed {
+		c.SlipAngle = math.Atan2(vx, vz)
+	}
+
+	if tireRadius > 0 {
+		for i, w := range wheelRotations(p) {
+			c.WheelSpeeds[i] = math.Abs(w) * tireRadius
+		}
+		c.WheelSpeed = drivenAverage(p.DrivetrainType, c.WheelSpeeds)
+	}
+
+	return c
+}
+
+// EstimateTireRadius guesses the tire radius in meters from speed over wheel
+// rotation. Only gives an answer when the car is moving with the tires gripping,
+// so feed it every packet and keep the last good value, TireRadius does that.
+func EstimateTireRadius(p *packethandling.ForzaHorizon5Packet) (float64, bool) {
+	speed := math.Hypot(float64(p.VelocityX), float64(p.VelocityZ))
+	if speed < minRadiusSpeed {
+		return 0, false
+	}
+
+	// Only trust wheels that are gripping, in a drift the driven ones will be spinning
+	slips := [4]float32{p.TireSlipRatioFrontLeft, p.TireSlipRatioFrontRight, p.TireSlipRatioRearLeft, p.TireSlipRatioRearRight}
+	var total float64
+	var count int
+	for i, w := range wheelRotations(p) {
+		if math.Abs(float64(slips[i])) <= maxRadiusSlip {
+			total += math.Abs(w)
+			count++
+		}
+	}
+	if count < minRadiusWheels {
+		return 0, false
+	}
+
+	mean := total / float64(count)
+	if mean < minWheelRotations {
+		return 0, false
+	}
+
+	return speed / mean, true
+}
+
+// TireRadius keeps a smoothed EstimateTireRadius across packets so the wheel
+// speeds derived from it hold steady. It starts again when the car changes.
+type TireRadius struct {
+	car    int32
+	radius float64
+}
+
+// Update feeds in a packet and returns the current estimate, 0 until there's one
+func (t *TireRadius) Update(p *packethandling.ForzaHorizon5Packet) float64 {
+	if p.Ordinal != t.car {
+		*t = TireRadius{car: p.Ordinal}
+	}
+	if r, ok := EstimateTireRadius(p); ok {
+		if t.radius == 0 {
+			t.radius = r
+		} else {
+			t.radius += radiusSmoothing * (r - t.radius)
+		}
+	}
+	return t.radius
+}
+
+func wheelRotations(p *packethandling.ForzaHorizon5Packet) [4]float64 {
+	return [4]float64{
+		float64(p.WheelRotationSpeedFrontLeft),
+		float64(p.WheelRotationSpeedFrontRight),
+		float64(p.WheelRotationSpeedRearLeft),
+		float64(p.WheelRotationSpeedRearRight),
+	}
+}
+
+// drivenAverage averages the wheels the drivetrain sends power to
+func drivenAverage(drivetrain packethandling.Drivetrain, wheels [4]float64) float64 {
+	switch drivetrain {
+	case packethandling.DrivetrainFWD:
+		return (wheels[0] + wheels[1]) / 2
+	case packethandling.DrivetrainRWD:
+		return (wheels[2] + wheels[3]) / 2
+	default:
+		return (wheels[0] + wheels[1] + wheels[2] + wheels[3]) / 4
+	}
+}
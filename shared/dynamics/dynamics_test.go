@@ -0,0 +1,157 @@
+package dynamics
+
+import (
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"math"
+	"testing"
+)
+
+func near(got, want float64) bool {
+	return math.Abs(got-want) < 1e-6
+}
+
+// The G channels come straight off the car frame acceleration: X right, Y up, Z forward
+func TestDeriveG(t *testing.T) {
+	p := packethandling.ForzaHorizon5Packet{AccelerationX: 9.80665, AccelerationY: -4.903325, AccelerationZ: -19.6133}
+	c := Derive(&p, 0)
+	if !near(c.LateralG, 1) || !near(c.VerticalG, -0.5) || !near(c.LongitudinalG, -2) {
+		t.Fatalf("got lateral %v, vertical %v, longitudinal %v", c.LateralG, c.VerticalG, c.LongitudinalG)
+	}
+}
+
+func TestSlipAngle(t *testing.T) {
+	tests := []struct {
+		name   string
+		vx, vz float32
+		want   float64
+	}{
+		{"straight", 0, 30, 0},
+		{"sliding right", 10, 10, math.Pi / 4},
+		{"sliding left", -10, 10, -math.Pi / 4},
+		{"sideways", 20, 0, math.Pi / 2},
+		{"reversing", 0, -5, math.Pi},
+		{"just fast enough", 0.6, 0.8, math.Atan2(0.6, 0.8)},
+		{"creeping", 0.5, 0.5, 0}, // 0.71 m/s is under the cutoff
+	}
+	for _, tt := range tests {
+		p := packethandling.ForzaHorizon5Packet{VelocityX: tt.vx, VelocityZ: tt.vz, VelocityY: 50}
+		c := Derive(&p, 0)
+		if !near(c.SlipAngle, tt.want) {
+			t.Errorf("%s: slip angle %v, want %v", tt.name, c.SlipAngle, tt.want)
+		}
+		if want := math.Hypot(float64(tt.vx), float64(tt.vz)); !near(c.SpeedOverGround, want) {
+			t.Errorf("%s: speed over ground %v, want %v, vertical speed counts", tt.name, c.SpeedOverGround, want)
+		}
+	}
+}
+
+func TestWheelSpeeds(t *testing.T) {
+	p := packethandling.ForzaHorizon5Packet{
+		WheelRotationSpeedFrontLeft:  10,
+		WheelRotationSpeedFrontRight: 10,
+		WheelRotationSpeedRearLeft:   20,
+		WheelRotationSpeedRearRight:  -20, // Only the speed counts
+		DrivetrainType:               packethandling.DrivetrainRWD,
+	}
+	if c := Derive(&p, 0); c.WheelSpeed != 0 || c.WheelSpeeds != [4]float64{} {
+		t.Fatalf("wheel speeds %v without a tire radius", c.WheelSpeeds)
+	}
+
+	c := Derive(&p, 0.3)
+	if c.WheelSpeeds != [4]float64{3, 3, 6, 6} || !near(c.WheelSpeed, 6) {
+		t.Fatalf("got %v averaging %v", c.WheelSpeeds, c.WheelSpeed)
+	}
+	p.DrivetrainType = packethandling.DrivetrainFWD
+	if c := Derive(&p, 0.3); !near(c.WheelSpeed, 3) {
+		t.Errorf("FWD averaged %v", c.WheelSpeed)
+	}
+	p.DrivetrainType = packethandling.DrivetrainAWD
+	if c := Derive(&p, 0.3); !near(c.WheelSpeed, 4.5) {
+		t.Errorf("AWD averaged %v", c.WheelSpeed)
+	}
+}
+
+// gripping returns a packet at speed with every wheel turning as a tire of radius would
+func gripping(speed, radius float64) packethandling.ForzaHorizon5Packet {
+	w := float32(speed / radius)
+	return packethandling.ForzaHorizon5Packet{
+		Ordinal:                      100,
+		VelocityZ:                    float32(speed),
+		WheelRotationSpeedFrontLeft:  w,
+		WheelRotationSpeedFrontRight: w,
+		WheelRotationSpeedRearLeft:   w,
+		WheelRotationSpeedRearRight:  w,
+	}
+}
+
+func TestEstimateTireRadius(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(p *packethandling.ForzaHorizon5Packet)
+		want float64 // 0 for no estimate
+	}{
+		{"gripping", func(p *packethandling.ForzaHorizon5Packet) {}, 0.35},
+		{"too slow", func(p *packethandling.ForzaHorizon5Packet) { *p = gripping(9, 0.35) }, 0},
+		{"minimum speed", func(p *packethandling.ForzaHorizon5Packet) { *p = gripping(10, 0.35) }, 0.35},
+		{"sideways counts", func(p *packethandling.ForzaHorizon5Packet) { p.VelocityX, p.VelocityZ = 20, 0 }, 0.35},
+		{"rears spinning", func(p *packethandling.ForzaHorizon5Packet) {
+			p.TireSlipRatioRearLeft, p.TireSlipRatioRearRight = 2, 2
+			p.WheelRotationSpeedRearLeft, p.WheelRotationSpeedRearRight = 200, 200
+		}, 0.35},
+		{"one wheel gripping", func(p *packethandling.ForzaHorizon5Packet) {
+			p.TireSlipRatioFrontRight, p.TireSlipRatioRearLeft, p.TireSlipRatioRearRight = 0.5, -0.5, 1
+		}, 0},
+		{"slip under the limit", func(p *packethandling.ForzaHorizon5Packet) {
+			p.TireSlipRatioFrontLeft, p.TireSlipRatioFrontRight, p.TireSlipRatioRearLeft, p.TireSlipRatioRearRight = 0.09, -0.09, 0.09, 0.09
+		}, 0.35},
+		{"slip over the limit", func(p *packethandling.ForzaHorizon5Packet) {
+			p.TireSlipRatioFrontLeft, p.TireSlipRatioFrontRight, p.TireSlipRatioRearLeft = 0.11, -0.11, 0.11
+		}, 0},
+		{"wheels locked", func(p *packethandling.ForzaHorizon5Packet) {
+			p.WheelRotationSpeedFrontLeft, p.WheelRotationSpeedFrontRight = 0, 0
+			p.WheelRotationSpeedRearLeft, p.WheelRotationSpeedRearRight = 0, 0
+		}, 0},
+	}
+	for _, tt := range tests {
+		p := gripping(20, 0.35)
+		tt.edit(&p)
+		got, ok := EstimateTireRadius(&p)
+		if ok != (tt.want != 0) || (ok && !near(got, tt.want)) {
+			t.Errorf("%s: got %v, %t, want %v", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestTireRadius(t *testing.T) {
+	var r TireRadius
+	slow := gripping(5, 0.35)
+	if got := r.Update(&slow); got != 0 {
+		t.Fatalf("got %v before any estimate", got)
+	}
+
+	// The first estimate is taken as is, later ones only nudge it
+	p := gripping(20, 0.35)
+	if got := r.Update(&p); !near(got, 0.35) {
+		t.Fatalf("first estimate %v", got)
+	}
+	p = gripping(20, 0.45)
+	if got := r.Update(&p); !near(got, 0.35+radiusSmoothing*0.1) {
+		t.Fatalf("second estimate %v", got)
+	}
+	// Packets without an estimate keep it
+	if got := r.Update(&slow); !near(got, 0.35+radiusSmoothing*0.1) {
+		t.Fatalf("kept %v", got)
+	}
+
+	// A different car starts again
+	other := gripping(5, 0.3)
+	other.Ordinal = 200
+	if got := r.Update(&other); got != 0 {
+		t.Fatalf("got %v after changing car", got)
+	}
+	other = gripping(20, 0.3)
+	other.Ordinal = 200
+	if got := r.Update(&other); !near(got, 0.3) {
+		t.Fatalf("new car's first estimate %v", got)
+	}
+}
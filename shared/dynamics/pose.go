@@ -0,0 +1,125 @@
+package dynamics
+
+import (
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"math"
+)
+
+// Vec3 uses the Forza axes: X = right, Y = up, Z = forward
+type Vec3 struct {
+	X, Y, Z float64
+}
+
+func (v Vec3) Add(o Vec3) Vec3 {
+	return Vec3{v.X + o.X, v.Y + o.Y, v.Z + o.Z}
+}
+
+func (v Vec3) Sub(o Vec3) Vec3 {
+	return Vec3{v.X - o.X, v.Y - o.Y, v.Z - o.Z}
+}
+
+func (v Vec3) Scale(s float64) Vec3 {
+	return Vec3{v.X * s, v.Y * s, v.Z * s}
+}
+
+func (v Vec3) Dot(o Vec3) float64 {
+	return v.X*o.X + v.Y*o.Y + v.Z*o.Z
+}
+
+func (v Vec3) Length() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// Mat3 is a row major 3x3 matrix
+type Mat3 [3][3]float64
+
+// Mul returns m * v
+func (m Mat3) Mul(v Vec3) Vec3 {
+	return Vec3{
+		m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z,
+	}
+}
+
+// MulMat returns m * o
+func (m Mat3) MulMat(o Mat3) Mat3 {
+	var r Mat3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i][j] = m[i][0]*o[0][j] + m[i][1]*o[1][j] + m[i][2]*o[2][j]
+		}
+	}
+	return r
+}
+
+// Transpose is also the inverse for a rotation matrix
+func (m Mat3) Transpose() Mat3 {
+	var r Mat3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i][j] = m[j][i]
+		}
+	}
+	return r
+}
+
+// Pose is where the car is and which way it's pointing, in world space
+type Pose struct {
+	Position         Vec3    // meters
+	Yaw, Pitch, Roll float64 // radians, yaw 0 faces world +Z
+}
+
+// PoseOf reads the pose out of a packet, position is only sent in formats with a dash
+func PoseOf(p *packethandling.ForzaHorizon5Packet) Pose {
+	return Pose{
+		Position: Vec3{float64(p.PositionX), float64(p.PositionY), float64(p.PositionZ)},
+		Yaw:      float64(p.Yaw),
+		Pitch:    float64(p.Pitch),
+		Roll:     float64(p.Roll),
+	}
+}
+
+// Rotation returns the car local to world rotation. Yaw turns about Y, pitch
+// about X and roll about Z, applied roll first then pitch then yaw.
+func (p Pose) Rotation() Mat3 {
+	sy, cy := math.Sincos(p.Yaw)
+	sp, cp := math.Sincos(p.Pitch)
+	sr, cr := math.Sincos(p.Roll)
+
+	yaw := Mat3{{cy, 0, sy}, {0, 1, 0}, {-sy, 0, cy}}
+	pitch := Mat3{{1, 0, 0}, {0, cp, -sp}, {0, sp, cp}}
+	roll := Mat3{{cr, -sr, 0}, {sr, cr, 0}, {0, 0, 1}}
+
+	return yaw.MulMat(pitch).MulMat(roll)
+}
+
+// LocalToWorld rotates a car local direction (velocity, acceleration...) into world space
+func (p Pose) LocalToWorld(v Vec3) Vec3 {
+	return p.Rotation().Mul(v)
+}
+
+// WorldToLocal rotates a world space direction into the car's frame
+func (p Pose) WorldToLocal(v Vec3) Vec3 {
+	return p.Rotation().Transpose().Mul(v)
+}
+
+// PointToWorld turns a point relative to the car (e.g. a wheel) into a world position
+func (p Pose) PointToWorld(v Vec3) Vec3 {
+	return p.LocalToWorld(v).Add(p.Position)
+}
+
+// Forward returns the direction the car's nose points in world space
+func (p Pose) Forward() Vec3 {
+	return p.LocalToWorld(Vec3{Z: 1})
+}
+
+// Right returns the car's right hand side in world space
+func (p Pose) Right() Vec3 {
+	return p.LocalToWorld(Vec3{X: 1})
+}
+
+// Up returns the car's roof direction in world space
+func (p Pose) Up() Vec3 {
+	return p.LocalToWorld(Vec3{Y: 1})
+}
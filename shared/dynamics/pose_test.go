@@ -0,0 +1,83 @@
+package dynamics
+
+import (
+	"math"
+	"testing"
+)
+
+func nearVec(got, want Vec3) bool {
+	return got.Sub(want).Length() < 1e-9
+}
+
+func TestPoseAxes(t *testing.T) {
+	tests := []struct {
+		name               string
+		pose               Pose
+		forward, right, up Vec3
+	}{
+		{"level", Pose{}, Vec3{Z: 1}, Vec3{X: 1}, Vec3{Y: 1}},
+		{"yawed a quarter turn", Pose{Yaw: math.Pi / 2}, Vec3{X: 1}, Vec3{Z: -1}, Vec3{Y: 1}},
+		{"yawed half a turn", Pose{Yaw: math.Pi}, Vec3{Z: -1}, Vec3{X: -1}, Vec3{Y: 1}},
+		{"rolled a quarter turn", Pose{Roll: math.Pi / 2}, Vec3{Z: 1}, Vec3{Y: 1}, Vec3{X: -1}},
+		{"pitched a quarter turn", Pose{Pitch: math.Pi / 2}, Vec3{Y: -1}, Vec3{X: 1}, Vec3{Z: 1}},
+	}
+	for _, tt := range tests {
+		if got := tt.pose.Forward(); !nearVec(got, tt.forward) {
+			t.Errorf("%s: forward %v, want %v", tt.name, got, tt.forward)
+		}
+		if got := tt.pose.Right(); !nearVec(got, tt.right) {
+			t.Errorf("%s: right %v, want %v", tt.name, got, tt.right)
+		}
+		if got := tt.pose.Up(); !nearVec(got, tt.up) {
+			t.Errorf("%s: up %v, want %v", tt.name, got, tt.up)
+		}
+	}
+}
+
+// WorldToLocal undoes LocalToWorld, the rotation's transpose is its inverse
+func TestRotationInverse(t *testing.T) {
+	poses := []Pose{
+		{},
+		{Yaw: 0.3, Pitch: -0.2, Roll: 0.1},
+		{Yaw: -2.5, Pitch: 1.2, Roll: -3},
+		{Yaw: math.Pi, Pitch: math.Pi / 2, Roll: math.Pi / 4},
+	}
+	vectors := []Vec3{{X: 1}, {Y: 1}, {Z: 1}, {1, -2, 3}}
+
+	for _, pose := range poses {
+		r := pose.Rotation()
+		identity := r.MulMat(r.Transpose())
+		for i := range 3 {
+			for j := range 3 {
+				want := 0.0
+				if i == j {
+					want = 1
+				}
+				if math.Abs(identity[i][j]-want) > 1e-9 {
+					t.Fatalf("%+v: R·Rᵀ is %v, not the identity", pose, identity)
+				}
+			}
+		}
+
+		for _, v := range vectors {
+			world := pose.LocalToWorld(v)
+			if !near(world.Length(), v.Length()) {
+				t.Errorf("%+v: %v changed length to %v", pose, v, world)
+			}
+			if got := pose.WorldToLocal(world); !nearVec(got, v) {
+				t.Errorf("%+v: %v came back as %v", pose, v, got)
+			}
+			if got := pose.LocalToWorld(pose.WorldToLocal(v)); !nearVec(got, v) {
+				t.Errorf("%+v: %v came back as %v the other way", pose, v, got)
+			}
+		}
+	}
+}
+
+func TestPointToWorld(t *testing.T) {
+	pose := Pose{Position: Vec3{100, 5, -20}, Yaw: math.Pi / 2}
+	// A wheel 1m to the right and 1.5m ahead of the middle
+	if got, want := pose.PointToWorld(Vec3{X: 1, Z: 1.5}), (Vec3{101.5, 5, -21}); !nearVec(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/source"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	// DefaultReadTimeout is how long a read waits before giving Idle a chance to run
+	DefaultReadTimeout = 500 * time.Millisecond
+
+	errorBackoff = 100 * time.Millisecond // Pause after a read error so a broken source doesn't spin
+)
+
+// Config is what Run calls back into
+type Config struct {
+	// ReadTimeout is the deadline for each read, <= 0 uses DefaultReadTimeout
+	ReadTimeout time.Duration
+
+	// Monitor is told about every packet and error, nil to not track the state
+	Monitor *Monitor
+
+	// Packet is called for every packet read, p is nil and err is why if it didn't parse.
+	// pkt and p are reused, so they're only valid until Packet returns.
+	Packet func(pkt source.Packet, p *packethandling.ForzaHorizon5Packet, err error)
+
+	// Idle is called whenever a read times out with nothing received, optional.
+	// If src is a source.Idler the Monitor is told whether it's idle on purpose.
+	Idle func()
+}
+
+// Run reads src until ctx is cancelled or the source ends. Malformed packets and
+// read errors are counted and skipped rather than stopping the loop.
+// Returns nil when ctx is cancelled, otherwise why the source ended.
+func Run(ctx context.Context, src source.PacketSource, cfg Config) error {
+	timeout := cfg.ReadTimeout
+	if timeout <= 0 {
+		timeout = DefaultReadTimeout
+	}
+
+	var p packethandling.ForzaHorizon5Packet
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, timeout)
+		pkt, err := src.Next(readCtx)
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+
+		case errors.Is(err, context.DeadlineExceeded):
+			if idler, ok := src.(source.Idler); ok && cfg.Monitor != nil {
+				cfg.Monitor.Idle(idler.Idle())
+			}
+			if cfg.Idle != nil {
+				cfg.Idle()
+			}
+			continue
+
+		case errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed):
+			if cfg.Monitor != nil {
+				cfg.Monitor.End(nil)
+			}
+			return err
+
+		case err != nil:
+			if cfg.Monitor != nil {
+				cfg.Monitor.ReadError(err)
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(errorBackoff):
+			}
+			continue
+		}
+
+		now := time.Now()
+		if err := packethandling.ParsePacket(pkt.Data, &p); err != nil {
+			if cfg.Monitor != nil {
+				cfg.Monitor.Malformed(err, now)
+			}
+			if cfg.Packet != nil {
+				cfg.Packet(pkt, nil, err)
+			}
+			continue
+		}
+
+		if cfg.Monitor != nil {
+			cfg.Monitor.Packet(p.IsRaceOn != 0, now)
+		}
+		if cfg.Packet != nil {
+			cfg.Packet(pkt, &p, nil)
+		}
+	}
+}
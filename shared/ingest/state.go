@@ -0,0 +1,171 @@
+// Package ingest runs the read loop every tool sits on: it pulls packets from a
+// source, keeps going through bad packets and read errors, and keeps track of
+// whether the game is actually sending anything.
+package ingest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultStallAfter is how long without a packet before the stream counts as stalled
+const DefaultStallAfter = 2 * time.Second
+
+// State is how the connection to the game looks right now
+type State int
+
+const (
+	StateWaiting   State = iota // Nothing received yet
+	StateStreaming              // Packets arriving and the race is on
+	StatePaused                 // Packets arriving but IsRaceOn is 0, menus or paused
+	StateStalled                // Had packets but nothing for a while
+	StateEnded                  // The source has finished, e.g. end of a recording
+	StateIdle                   // The source is holding packets back, e.g. a paused replay
+)
+
+func (s State) String() string {
+	switch s {
+	case StateWaiting:
+		return "waiting for game"
+	case StateStreaming:
+		return "streaming"
+	case StatePaused:
+		return "game paused"
+	case StateStalled:
+		return "stalled"
+	case StateEnded:
+		return "ended"
+	case StateIdle:
+		return "idle"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Status is a snapshot of the connection
+type Status struct {
+	State     State
+	Silence   time.Duration // Time since the last packet, or since starting if there hasn't been one
+	Packets   uint64        // Packets that parsed
+	Malformed uint64        // Packets that didn't parse and were skipped
+	Errors    uint64        // Read errors the loop carried on through
+	LastError error         // Most recent malformed packet or read error, nil if none
+}
+
+func (s Status) String() string {
+	switch s.State {
+	case StateWaiting:
+		return fmt.Sprintf("Waiting for game (%ds)", int(s.Silence.Seconds()))
+	case StateStalled:
+		return fmt.Sprintf("Stalled for %ds", int(s.Silence.Seconds()))
+	case StatePaused:
+		return "Game paused"
+	case StateIdle:
+		return "Source paused"
+	case StateStreaming:
+		return "Streaming"
+	default:
+		if s.LastError != nil {
+			return fmt.Sprintf("Ended: %v", s.LastError)
+		}
+		return "Ended"
+	}
+}
+
+// Monitor works out the State from what the read loop sees, it's safe to read
+// Status from another goroutine
+type Monitor struct {
+	mu         sync.Mutex
+	stallAfter time.Duration
+	started    time.Time
+	last       time.Time // Zero until the first packet
+	raceOn     bool
+	ended      bool
+	idle       bool
+	packets    uint64
+	malformed  uint64
+	errors     uint64
+	lastErr    error
+}
+
+// NewMonitor starts the clock, stallAfter <= 0 uses DefaultStallAfter
+func NewMonitor(stallAfter time.Duration) *Monitor {
+	if stallAfter <= 0 {
+		stallAfter = DefaultStallAfter
+	}
+	return &Monitor{stallAfter: stallAfter, started: time.Now()}
+}
+
+// Packet records a good packet arriving at now
+func (m *Monitor) Packet(raceOn bool, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = now
+	m.raceOn = raceOn
+	m.idle = false
+	m.packets++
+}
+
+// Malformed records a packet that was skipped. It still shows the game is sending.
+func (m *Monitor) Malformed(err error, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = now
+	m.malformed++
+	m.lastErr = err
+}
+
+// ReadError records a read error that the loop carried on from
+func (m *Monitor) ReadError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors++
+	m.lastErr = err
+}
+
+// Idle records whether the source is holding packets back on purpose, see source.Idler
+func (m *Monitor) Idle(idle bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.idle = idle
+}
+
+// End marks the source as finished, err is why (nil for a normal end)
+func (m *Monitor) End(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ended = true
+	if err != nil {
+		m.lastErr = err
+	}
+}
+
+// Status works out the state as of now
+func (m *Monitor) Status(now time.Time) Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := Status{Packets: m.packets, Malformed: m.malformed, Errors: m.errors, LastError: m.lastErr}
+	if m.last.IsZero() {
+		s.Silence = now.Sub(m.started)
+	} else {
+		s.Silence = now.Sub(m.last)
+	}
+
+	switch {
+	case m.ended:
+		s.State = StateEnded
+	case m.idle:
+		s.State = StateIdle
+	case m.last.IsZero():
+		s.State = StateWaiting
+	case s.Silence >= m.stallAfter:
+		s.State = StateStalled
+	case !m.raceOn:
+		s.State = StatePaused
+	default:
+		s.State = StateStreaming
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package ingest
+
+import (
+	"context"
+	"forza-horizon-5-telemetry/shared/source"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMonitorStates(t *testing.T) {
+	m := NewMonitor(time.Second)
+	now := m.started
+
+	steps := []struct {
+		do   func()
+		at   time.Duration
+		want State
+	}{
+		{func() {}, 0, StateWaiting},
+		{func() {}, 5 * time.Second, StateWaiting}, // Never had anything to stall from
+		{func() { m.Packet(true, now.Add(5*time.Second)) }, 5 * time.Second, StateStreaming},
+		{func() {}, 5*time.Second + 999*time.Millisecond, StateStreaming},
+		{func() {}, 6 * time.Second, StateStalled},
+		{func() { m.Idle(true) }, 7 * time.Second, StateIdle},
+		{func() { m.Packet(false, now.Add(8*time.Second)) }, 8 * time.Second, StatePaused},
+		{func() { m.Idle(true) }, 20 * time.Second, StateIdle},
+		{func() { m.Idle(false) }, 20 * time.Second, StateStalled},
+		{func() { m.End(nil) }, 20 * time.Second, StateEnded},
+	}
+	for i, s := range steps {
+		s.do()
+		if got := m.Status(now.Add(s.at)).State; got != s.want {
+			t.Fatalf("step %d: got %s, want %s", i, got, s.want)
+		}
+	}
+}
+
+// idleSource never has a packet, and says whether that's on purpose
+type idleSource struct {
+	idle atomic.Bool
+}
+
+func (s *idleSource) Next(ctx context.Context) (source.Packet, error) {
+	<-ctx.Done()
+	return source.Packet{}, ctx.Err()
+}
+
+func (s *idleSource) Name() string { return "idle" }
+func (s *idleSource) Close() error { return nil }
+func (s *idleSource) Idle() bool   { return s.idle.Load() }
+
+// A source that's quiet on purpose, like a paused replay, isn't stalled
+func TestRunIdleSource(t *testing.T) {
+	for _, idle := range []bool{true, false} {
+		src := &idleSource{}
+		src.idle.Store(idle)
+		m := NewMonitor(10 * time.Millisecond)
+		m.Packet(true, time.Now())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		Run(ctx, src, Config{ReadTimeout: 20 * time.Millisecond, Monitor: m})
+		cancel()
+
+		want := StateStalled
+		if idle {
+			want = StateIdle
+		}
+		if got := m.Status(time.Now()).State; got != want {
+			t.Errorf("idle %t: got %s, want %s", idle, got, want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package packethandling
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"unsafe"
+)
+
+// ParseBatch decodes back to back packets of one format from data into dst, e.g. a
+// whole memory mapped recording. Returns how many packets were decoded, which stops
+// at len(dst) or the end of data. Nothing is allocated, dst is reused as is.
+func ParseBatch(data []byte, format Format, dst []ForzaHorizon5Packet) (int, error) {
+	size := format.Size()
+	if size == 0 {
+		return 0, fmt.Errorf("unsupported packet format: %s", format)
+	}
+
+	n := 0
+	for ; n < len(dst) && (n+1)*size <= len(data); n++ {
+		dst[n] = ForzaHorizon5Packet{Format: format}
+		decodePlan(data[n*size:(n+1)*size], unsafe.Pointer(&dst[n]), plans[format])
+	}
+	return n, nil
+}
+
+// Columns holds decoded packets as one float64 slice per field, which is what
+// most offline analysis wants. Build it once with NewColumns and reuse it.
+type Columns struct {
+	Fields []*Field
+	Values [][]float64 // Values[i] holds the column for Fields[i]
+	Len    int         // Number of rows currently filled
+}
+
+// NewColumns makes room for capacity packets of the named fields, no names means every field
+func NewColumns(capacity int, names ...string) (*Columns, error) {
+	c := &Columns{}
+	if len(names) == 0 {
+		for i := range fields {
+			c.Fields = append(c.Fields, &fields[i])
+		}
+	}
+	for _, name := range names {
+		f, ok := LookupField(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field: %s", name)
+		}
+		c.Fields = append(c.Fields, f)
+	}
+
+	c.Values = make([][]float64, len(c.Fields))
+	for i := range c.Values {
+		c.Values[i] = make([]float64, capacity)
+	}
+	return c, nil
+}
+
+// Cap returns how many rows fit in the columns
+func (c *Columns) Cap() int {
+	if len(c.Values) == 0 {
+		return 0
+	}
+	return len(c.Values[0])
+}
+
+// Column returns the filled part of the named column, nil if it wasn't requested
+func (c *Columns) Column(name string) []float64 {
+	for i, f := range c.Fields {
+		if f.Name == name {
+			return c.Values[i][:c.Len]
+		}
+	}
+	return nil
+}
+
+// ParseColumns decodes back to back packets of one format from data straight into
+// the columns, overwriting what was there. Returns how many packets were decoded.
+func ParseColumns(data []byte, format Format, c *Columns) (int, error) {
+	size := format.Size()
+	if size == 0 {
+		return 0, fmt.Errorf("unsupported packet format: %s", format)
+	}
+
+	c.Len = min(c.Cap(), len(data)/size)
+	for i, f := range c.Fields {
+		col := c.Values[i]
+		offset, ok := f.OffsetFor(format)
+		if !ok {
+			clear(col[:c.Len])
+			continue
+		}
+		for row := 0; row < c.Len; row++ {
+			col[row] = f.decodeFloat(data[row*size+offset:])
+		}
+	}
+	return c.Len, nil
+}
+
+// setRow decodes one packet of any format into a row of the columns
+func (c *Columns) setRow(row int, packet []byte) error {
+	format := DetectFormat(len(packet))
+	if format == FormatUnknown {
+		return fmt.Errorf("unsupported packet size: %d bytes", len(packet))
+	}
+	for i, f := range c.Fields {
+		if offset, ok := f.OffsetFor(format); ok {
+			c.Values[i][row] = f.decodeFloat(packet[offset:])
+		} else {
+			c.Values[i][row] = 0
+		}
+	}
+	return nil
+}
+
+// decodeFloat reads the field straight from the wire as a float64
+func (f *Field) decodeFloat(b []byte) float64 {
+	switch f.Type {
+	case TypeInt8:
+		return float64(int8(b[0]))
+	case TypeUint8:
+		return float64(b[0])
+	case TypeUint16:
+		return float64(binary.LittleEndian.Uint16(b))
+	case TypeInt32:
+		return float64(int32(binary.LittleEndian.Uint32(b)))
+	case TypeUint32:
+		return float64(binary.LittleEndian.Uint32(b))
+	case TypeInt64:
+		return float64(int64(binary.LittleEndian.Uint64(b)))
+	case TypeFloat32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	default:
+		return 0
+	}
+}
+
+// PacketReader hands out one datagram at a time, e.g. a recording.Reader. The
+// data only has to stay valid until the next call.
+type PacketReader interface {
+	ReadPacket() ([]byte, error)
+}
+
+// BatchReader decodes packets a batch at a time, either from a stream of back to
+// back packets of one format (e.g. a legacy debug stream file) or from a
+// PacketReader. Its read buffer is reused, so once it has grown to the batch size
+// nothing else is allocated.
+type BatchReader struct {
+	r       io.Reader
+	packets PacketReader // Set instead of r by NewPacketBatchReader
+	format  Format
+	buf     []byte
+}
+
+func NewBatchReader(r io.Reader, format Format) (*BatchReader, error) {
+	if format.Size() == 0 {
+		return nil, fmt.Errorf("unsupported packet format: %s", format)
+	}
+	return &BatchReader{r: r, format: format}, nil
+}
+
+// NewPacketBatchReader reads packets from r, which can mix formats, e.g.
+// recordings and gzipped recordings through recording.Reader
+func NewPacketBatchReader(r PacketReader) *BatchReader {
+	return &BatchReader{packets: r}
+}
+
+// Read decodes up to len(dst) packets into dst. Returns io.EOF once the stream is
+// done, and an error if it ends part way through a packet. An error from a
+// PacketReader comes back with the packets read before it.
+func (b *BatchReader) Read(dst []ForzaHorizon5Packet) (int, error) {
+	if b.packets != nil {
+		for n := range dst {
+			packet, err := b.packets.ReadPacket()
+			if err == nil {
+				err = ParsePacket(packet, &dst[n])
+			}
+			if err != nil {
+				return n, err
+			}
+		}
+		return len(dst), nil
+	}
+
+	data, err := b.fill(len(dst))
+	n, parseErr := ParseBatch(data, b.format, dst)
+	if parseErr != nil {
+		return n, parseErr
+	}
+	return n, err
+}
+
+// ReadColumns decodes up to c.Cap() packets into the columns, same rules as Read
+func (b *BatchReader) ReadColumns(c *Columns) (int, error) {
+	if b.packets != nil {
+		c.Len = 0
+		for c.Len < c.Cap() {
+			packet, err := b.packets.ReadPacket()
+			if err == nil {
+				err = c.setRow(c.Len, packet)
+			}
+			if err != nil {
+				return c.Len, err
+			}
+			c.Len++
+		}
+		return c.Len, nil
+	}
+
+	data, err := b.fill(c.Cap())
+	n, parseErr := ParseColumns(data, b.format, c)
+	if parseErr != nil {
+		return n, parseErr
+	}
+	return n, err
+}
+
+// fill reads up to count whole packets into the buffer
+func (b *BatchReader) fill(count int) ([]byte, error) {
+	size := b.format.Size()
+	if cap(b.buf) < count*size {
+		b.buf = make([]byte, count*size)
+	}
+	buf := b.buf[:count*size]
+
+	n, err := io.ReadFull(b.r, buf)
+	switch {
+	case err == io.ErrUnexpectedEOF && n%size == 0:
+		err = nil // Stream ended on a packet boundary, the next read gets io.EOF
+	case err == io.ErrUnexpectedEOF:
+		err = fmt.Errorf("stream ends part way through a packet: %d trailing bytes", n%size)
+	}
+	return buf[:n-n%size], err
+}
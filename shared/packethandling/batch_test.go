@@ -0,0 +1,145 @@
+package packethandling
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+)
+
+// benchPackets returns n Horizon packets back to back
+func benchPackets(n int) []byte {
+	var data []byte
+	for i := range n {
+		p := ForzaHorizon5Packet{IsRaceOn: 1, TimeStampMS: uint32(i), Speed: float32(i), CurrentEngineRpm: 5000}
+		data = AppendPacket(data, &p)
+	}
+	return data
+}
+
+func BenchmarkParsePacket(b *testing.B) {
+	data := benchPackets(1)
+	var p ForzaHorizon5Packet
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		if err := ParsePacket(data, &p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseBatch(b *testing.B) {
+	const n = 1024
+	data := benchPackets(n)
+	dst := make([]ForzaHorizon5Packet, n)
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		if _, err := ParseBatch(data, FormatHorizon, dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseColumns(b *testing.B) {
+	const n = 1024
+	data := benchPackets(n)
+	c, err := NewColumns(n, "Speed", "CurrentEngineRpm", "TimeStampMS")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		if _, err := ParseColumns(data, FormatHorizon, c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseBatch(t *testing.T) {
+	data := benchPackets(5)
+	dst := make([]ForzaHorizon5Packet, 3)
+	n, err := ParseBatch(data, FormatHorizon, dst)
+	if err != nil || n != 3 {
+		t.Fatalf("got %d, %v, want 3 packets", n, err)
+	}
+	for i, p := range dst {
+		if p.TimeStampMS != uint32(i) || p.Format != FormatHorizon {
+			t.Errorf("packet %d: got timestamp %d format %s", i, p.TimeStampMS, p.Format)
+		}
+	}
+}
+
+// packetList is a PacketReader over packets in memory
+type packetList [][]byte
+
+func (l *packetList) ReadPacket() ([]byte, error) {
+	if len(*l) == 0 {
+		return nil, io.EOF
+	}
+	p := (*l)[0]
+	*l = (*l)[1:]
+	return p, nil
+}
+
+// A PacketReader can mix formats, fields a format doesn't have read as zero
+func TestPacketBatchReader(t *testing.T) {
+	sled := ForzaHorizon5Packet{Format: FormatMotorsport7Sled, TimeStampMS: 1, CurrentEngineRpm: 1000}
+	dash := ForzaHorizon5Packet{Format: FormatMotorsport2023, TimeStampMS: 2, CurrentEngineRpm: 2000, Speed: 30}
+	horizon := ForzaHorizon5Packet{TimeStampMS: 3, CurrentEngineRpm: 3000, Speed: 40}
+	packets := func() *packetList {
+		return &packetList{MarshalPacket(&sled), MarshalPacket(&dash), MarshalPacket(&horizon)}
+	}
+
+	br := NewPacketBatchReader(packets())
+	dst := make([]ForzaHorizon5Packet, 2)
+	if n, err := br.Read(dst); n != 2 || err != nil {
+		t.Fatalf("got %d, %v, want 2 packets", n, err)
+	}
+	if dst[0].Format != FormatMotorsport7Sled || dst[1].Format != FormatMotorsport2023 || dst[1].Speed != 30 {
+		t.Fatalf("got %+v", dst)
+	}
+	if n, err := br.Read(dst); n != 1 || err != io.EOF {
+		t.Fatalf("got %d, %v, want the last packet and io.EOF", n, err)
+	}
+
+	c, err := NewColumns(4, "CurrentEngineRpm", "Speed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	br = NewPacketBatchReader(packets())
+	if n, err := br.ReadColumns(c); n != 3 || err != io.EOF {
+		t.Fatalf("got %d, %v, want 3 rows and io.EOF", n, err)
+	}
+	if got := c.Column("CurrentEngineRpm"); !slices.Equal(got, []float64{1000, 2000, 3000}) {
+		t.Errorf("CurrentEngineRpm %v", got)
+	}
+	if got := c.Column("Speed"); !slices.Equal(got, []float64{0, 30, 40}) {
+		t.Errorf("Speed %v", got)
+	}
+}
+
+// A raw stream has to give the same columns as ParseColumns
+func TestBatchReaderColumns(t *testing.T) {
+	data := benchPackets(10)
+	want, _ := NewColumns(10, "Speed")
+	ParseColumns(data, FormatHorizon, want)
+
+	br, err := NewBatchReader(bytes.NewReader(data), FormatHorizon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := NewColumns(4, "Speed")
+	var all []float64
+	for {
+		n, err := br.ReadColumns(got)
+		all = append(all, got.Column("Speed")[:n]...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(all, want.Column("Speed")) {
+		t.Fatalf("got %v, want %v", all, want.Column("Speed"))
+	}
+}
@@ -0,0 +1,212 @@
+package packethandling
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// CarClass is the car's performance class. Each game numbers its classes
+// differently, String uses the Horizon names and Name the ones for a format.
+type CarClass int32
+
+const (
+	CarClassD CarClass = iota
+	CarClassC
+	CarClassB
+	CarClassA
+	CarClassS1
+	CarClassS2
+	CarClassX
+)
+
+var (
+	carClassNames            = []string{"D", "C", "B", "A", "S1", "S2", "X"}
+	motorsport7ClassNames    = []string{"D", "C", "B", "A", "S", "R", "P", "X"}
+	motorsport2023ClassNames = []string{"E", "D", "C", "B", "A", "S", "R", "P", "X"}
+)
+
+// classNames returns the class names of the game that sends format, Horizon's if it's unknown
+func classNames(f Format) []string {
+	switch f {
+	case FormatMotorsport7Sled, FormatMotorsport7Dash:
+		return motorsport7ClassNames
+	case FormatMotorsport2023:
+		return motorsport2023ClassNames
+	default:
+		return carClassNames
+	}
+}
+
+// Name returns the class the way the game that sent format shows it, e.g. 4
+// is "S1" in Horizon but "S" in Motorsport 7
+func (c CarClass) Name(f Format) string {
+	names := classNames(f)
+	if c < 0 || int(c) >= len(names) {
+		return fmt.Sprintf("CarClass(%d)", int32(c))
+	}
+	return names[c]
+}
+
+// valid returns true if the game that sends format has the class
+func (c CarClass) valid(f Format) bool {
+	return c >= 0 && int(c) < len(classNames(f))
+}
+
+func (c CarClass) String() string {
+	if c < 0 || int(c) >= len(carClassNames) {
+		return fmt.Sprintf("CarClass(%d)", int32(c))
+	}
+	return carClassNames[c]
+}
+
+// MarshalText writes the class name, or the raw number for one that isn't known
+// so UnmarshalText gets the same class back
+func (c CarClass) MarshalText() ([]byte, error) {
+	if c < 0 || int(c) >= len(carClassNames) {
+		return strconv.AppendInt(nil, int64(c), 10), nil
+	}
+	return []byte(carClassNames[c]), nil
+}
+
+// UnmarshalText accepts a class name ("S1") or the raw number
+func (c *CarClass) UnmarshalText(text []byte) error {
+	v, err := parseEnum(text, carClassNames)
+	if err != nil {
+		return fmt.Errorf("invalid car class %q", text)
+	}
+	*c = CarClass(v)
+	return nil
+}
+
+// CarClassFromPI works out the Horizon class from a performance index, using the Horizon 5 bands
+func CarClassFromPI(pi int32) CarClass {
+	switch {
+	case pi <= 500:
+		return CarClassD
+	case pi <= 600:
+		return CarClassC
+	case pi <= 700:
+		return CarClassB
+	case pi <= 800:
+		return CarClassA
+	case pi <= 900:
+		return CarClassS1
+	case pi <= 998:
+		return CarClassS2
+	default:
+		return CarClassX
+	}
+}
+
+// Drivetrain is which wheels are driven
+type Drivetrain int32
+
+const (
+	DrivetrainFWD Drivetrain = iota
+	DrivetrainRWD
+	DrivetrainAWD
+)
+
+var drivetrainNames = []string{"FWD", "RWD", "AWD"}
+
+func (d Drivetrain) String() string {
+	if d < 0 || int(d) >= len(drivetrainNames) {
+		return fmt.Sprintf("Drivetrain(%d)", int32(d))
+	}
+	return drivetrainNames[d]
+}
+
+// MarshalText writes the drivetrain name, or the raw number like CarClass
+func (d Drivetrain) MarshalText() ([]byte, error) {
+	if d < 0 || int(d) >= len(drivetrainNames) {
+		return strconv.AppendInt(nil, int64(d), 10), nil
+	}
+	return []byte(drivetrainNames[d]), nil
+}
+
+// UnmarshalText accepts a drivetrain name ("AWD") or the raw number
+func (d *Drivetrain) UnmarshalText(text []byte) error {
+	v, err := parseEnum(text, drivetrainNames)
+	if err != nil {
+		return fmt.Errorf("invalid drivetrain %q", text)
+	}
+	*d = Drivetrain(v)
+	return nil
+}
+
+// CarType is the Horizon car category (Track Toys, Super GT...), only sent by Horizon
+type CarType int32
+
+var carTypeNames = map[CarType]string{
+	11: "Modern Supercars",
+	12: "Retro Supercars",
+	13: "Hypercars",
+	14: "Retro Saloons",
+	16: "Vans & Utility",
+	17: "Retro Sports Cars",
+	18: "Modern Sports Cars",
+	19: "Super Saloons",
+	20: "Classic Racers",
+	21: "Cult Cars",
+	22: "Rare Classics",
+	25: "Super Hot Hatch",
+	29: "Rods & Customs",
+	30: "Retro Muscle",
+	31: "Modern Muscle",
+	32: "Retro Rally",
+	33: "Classic Rally",
+	34: "Rally Monsters",
+	35: "Modern Rally",
+	36: "GT Cars",
+	37: "Super GT",
+	38: "Extreme Offroad",
+	39: "Sports Utility Heroes",
+	40: "Offroad",
+	41: "Offroad Buggies",
+	42: "Classic Sports Cars",
+	43: "Track Toys",
+	44: "Vintage Racers",
+	45: "Trucks",
+}
+
+func (t CarType) String() string {
+	if name, ok := carTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("CarType(%d)", int32(t))
+}
+
+// MarshalText writes the category name, or the raw number like CarClass
+func (t CarType) MarshalText() ([]byte, error) {
+	if name, ok := carTypeNames[t]; ok {
+		return []byte(name), nil
+	}
+	return strconv.AppendInt(nil, int64(t), 10), nil
+}
+
+// UnmarshalText accepts a category name ("Track Toys") or the raw number
+func (t *CarType) UnmarshalText(text []byte) error {
+	for k, name := range carTypeNames {
+		if name == string(text) {
+			*t = k
+			return nil
+		}
+	}
+	v, err := strconv.ParseInt(string(text), 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid car type %q", text)
+	}
+	*t = CarType(v)
+	return nil
+}
+
+// parseEnum looks text up in names, falling back to a raw number
+func parseEnum(text []byte, names []string) (int32, error) {
+	for i, name := range names {
+		if name == string(text) {
+			return int32(i), nil
+		}
+	}
+	v, err := strconv.ParseInt(string(text), 10, 32)
+	return int32(v), err
+}
@@ -0,0 +1,88 @@
+package packethandling
+
+import (
+	"encoding"
+	"encoding/json"
+	"testing"
+)
+
+// Every value has to come back the same after a text round trip, known or not
+func TestEnumTextRoundTrip(t *testing.T) {
+	tests := []struct {
+		in   encoding.TextMarshaler
+		want string
+		out  func() encoding.TextUnmarshaler
+	}{
+		{CarClassS1, "S1", func() encoding.TextUnmarshaler { return new(CarClass) }},
+		{CarClassX, "X", func() encoding.TextUnmarshaler { return new(CarClass) }},
+		{CarClass(7), "7", func() encoding.TextUnmarshaler { return new(CarClass) }},
+		{CarClass(-1), "-1", func() encoding.TextUnmarshaler { return new(CarClass) }},
+		{DrivetrainAWD, "AWD", func() encoding.TextUnmarshaler { return new(Drivetrain) }},
+		{Drivetrain(3), "3", func() encoding.TextUnmarshaler { return new(Drivetrain) }},
+		{CarType(43), "Track Toys", func() encoding.TextUnmarshaler { return new(CarType) }},
+		{CarType(99), "99", func() encoding.TextUnmarshaler { return new(CarType) }},
+	}
+	for _, tt := range tests {
+		text, err := tt.in.MarshalText()
+		if err != nil || string(text) != tt.want {
+			t.Errorf("%v: marshalled to %q, %v, want %q", tt.in, text, err, tt.want)
+			continue
+		}
+		out := tt.out()
+		if err := out.UnmarshalText(text); err != nil {
+			t.Errorf("%v: %v", tt.in, err)
+			continue
+		}
+		if got := out.(interface{ String() string }).String(); got != tt.in.(interface{ String() string }).String() {
+			t.Errorf("%v: came back as %s", tt.in, got)
+		}
+	}
+}
+
+func TestCarClassJSON(t *testing.T) {
+	in := map[string]CarClass{"known": CarClassA, "unknown": 12}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]CarClass
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("%s: %v", b, err)
+	}
+	if out["known"] != CarClassA || out["unknown"] != 12 {
+		t.Fatalf("%s came back as %v", b, out)
+	}
+}
+
+// The same class number is a different class in each game
+func TestCarClassName(t *testing.T) {
+	tests := []struct {
+		format Format
+		class  CarClass
+		want   string
+	}{
+		{FormatHorizon, 0, "D"},
+		{FormatHorizon, 4, "S1"},
+		{FormatHorizon, 6, "X"},
+		{FormatHorizon, 7, "CarClass(7)"},
+		{FormatUnknown, 5, "S2"},
+		{FormatMotorsport7Sled, 4, "S"},
+		{FormatMotorsport7Dash, 5, "R"},
+		{FormatMotorsport7Dash, 7, "X"},
+		{FormatMotorsport7Dash, 8, "CarClass(8)"},
+		{FormatMotorsport2023, 0, "E"},
+		{FormatMotorsport2023, 5, "S"},
+		{FormatMotorsport2023, 8, "X"},
+		{FormatMotorsport2023, -1, "CarClass(-1)"},
+	}
+	for _, tt := range tests {
+		if got := tt.class.Name(tt.format); got != tt.want {
+			t.Errorf("%s class %d: got %s, want %s", tt.format, tt.class, got, tt.want)
+		}
+	}
+
+	p := ForzaHorizon5Packet{Format: FormatMotorsport7Dash, CarClass: 4, CarPerformanceIndex: 750, DrivetrainType: DrivetrainRWD}
+	if got := p.GetCarSummary(); got != "S 750 RWD" {
+		t.Errorf("got summary %q", got)
+	}
+}
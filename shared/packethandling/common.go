@@ -3,19 +3,12 @@ package packethandling
 import (
 	"fmt"
 	"net"
-
-	"github.com/davecgh/go-spew/spew"
-
 	"strings"
 )
 
+// Setup listens on a single address and port, see Listen for the other options
 func Setup(ipAddr string, port int) (*net.UDPConn, error) {
-	addr := net.UDPAddr{
-		IP:   net.ParseIP(ipAddr),
-		Port: port,
-	}
-
-	return net.ListenUDP("udp", &addr)
+	return Listen(ListenConfig{Address: ipAddr, Port: port})
 }
 
 // Prints out a nice hex-view of the data
@@ -66,16 +59,26 @@ func FormatByteArray(data []byte, limit int) string {
 	return sb.String()
 }
 
-// Pretty prints the struct using spew
+// Pretty prints the packet, one group at a time, skipping fields the format doesn't send
 func FormatStruct(d ForzaHorizon5Packet) string {
-	config := spew.ConfigState{
-		Indent:                  "    ",
-		MaxDepth:                0,
-		DisableMethods:          true,
-		DisablePointerAddresses: true,
-		DisableCapacities:       true,
-		SortKeys:                true,
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Format: %s (%d bytes)\n", d.Format, d.Format.Size()))
+
+	for _, group := range Groups {
+		sb.WriteString(fmt.Sprintf("\n%s\n", group))
+		for i := range fields {
+			f := &fields[i]
+			if f.Group != group {
+				continue
+			}
+			if _, ok := f.OffsetFor(d.Format); !ok {
+				continue
+			}
+			sb.WriteString(strings.TrimRight(fmt.Sprintf("    %-38s %s %s", f.Name, f.String(&d), f.Unit), " "))
+			sb.WriteString("\n")
+		}
 	}
-	return config.Sdump(d)
 
+	return sb.String()
 }
@@ -0,0 +1,86 @@
+package packethandling
+
+import "fmt"
+
+// Format is the "Data Out" layout a datagram was sent in.
+// Forza doesn't tag its packets, so the only way to tell them apart is the size.
+type Format int
+
+const (
+	FormatUnknown         Format = iota
+	FormatMotorsport7Sled        // Forza Motorsport 7 "Sled"
+	FormatMotorsport7Dash        // Forza Motorsport 7 "Car Dash"
+	FormatHorizon                // Forza Horizon 4 / 5
+	FormatMotorsport2023         // Forza Motorsport (2023) "Car Dash"
+)
+
+const (
+	sizeMotorsport7Sled = 232
+	sizeMotorsport7Dash = 311
+	sizeHorizon         = 324
+	sizeMotorsport2023  = 331
+
+	// Horizon sticks 12 extra bytes (CarType + ObjectHit) between the sled and
+	// dash sections, so every dash field sits 12 bytes earlier in Motorsport.
+	motorsportDashShift = 12
+)
+
+// DetectFormat works out the packet format from the datagram length
+func DetectFormat(size int) Format {
+	switch size {
+	case sizeMotorsport7Sled:
+		return FormatMotorsport7Sled
+	case sizeMotorsport7Dash:
+		return FormatMotorsport7Dash
+	case sizeHorizon:
+		return FormatHorizon
+	case sizeMotorsport2023:
+		return FormatMotorsport2023
+	default:
+		return FormatUnknown
+	}
+}
+
+// Size returns the datagram length for the format, 0 if unknown
+func (f Format) Size() int {
+	switch f {
+	case FormatMotorsport7Sled:
+		return sizeMotorsport7Sled
+	case FormatMotorsport7Dash:
+		return sizeMotorsport7Dash
+	case FormatHorizon:
+		return sizeHorizon
+	case FormatMotorsport2023:
+		return sizeMotorsport2023
+	default:
+		return 0
+	}
+}
+
+// HasDash returns true if the format carries the dash section (position, speed, laps, controls...)
+func (f Format) HasDash() bool {
+	return f == FormatMotorsport7Dash || f == FormatHorizon || f == FormatMotorsport2023
+}
+
+// dashShift is how far the dash fields are moved back compared to the Horizon offsets
+func (f Format) dashShift() int {
+	if f == FormatHorizon {
+		return 0
+	}
+	return motorsportDashShift
+}
+
+func (f Format) String() string {
+	switch f {
+	case FormatMotorsport7Sled:
+		return "FM7 Sled"
+	case FormatMotorsport7Dash:
+		return "FM7 Dash"
+	case FormatHorizon:
+		return "Horizon"
+	case FormatMotorsport2023:
+		return "FM 2023"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(f))
+	}
+}
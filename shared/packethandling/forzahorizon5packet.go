@@ -1,8 +1,14 @@
 package packethandling
 
-import "math"
+import (
+	"fmt"
+	"forza-horizon-5-telemetry/shared/units"
+)
 
+// ForzaHorizon5Packet holds any of the supported formats, fields the format
+// doesn't send are left at zero. Check Format to see which ones are real.
 type ForzaHorizon5Packet struct {
+	Format                               Format // not on the wire, worked out from the packet size
 	IsRaceOn                             int32
 	TimeStampMS                          uint32
 	EngineMaxRpm                         float32
@@ -57,11 +63,11 @@ type ForzaHorizon5Packet struct {
 	SuspensionTravelMetersRearLeft       float32
 	SuspensionTravelMetersRearRight      float32
 	Ordinal                              int32
-	CarClass                             int32
+	CarClass                             CarClass
 	CarPerformanceIndex                  int32
-	DrivetrainType                       int32
-	NumOfCylinders                       uint8
-	CarType                              int32
+	DrivetrainType                       Drivetrain
+	NumOfCylinders                       int32
+	CarType                              CarType
 	ObjectHit                            int64 // long in Java
 	PositionX                            float32
 	PositionY                            float32
@@ -90,6 +96,13 @@ type ForzaHorizon5Packet struct {
 	Steer                                int8
 	NormalizedDrivingLine                uint8
 	NormalizedAIBrakeDifference          uint8
+
+	// Forza Motorsport (2023) only
+	TireWearFrontLeft  float32
+	TireWearFrontRight float32
+	TireWearRearLeft   float32
+	TireWearRearRight  float32
+	TrackOrdinal       int32
 }
 
 // GetIsRaceOn returns true if race is active
@@ -107,61 +120,59 @@ func (d *ForzaHorizon5Packet) GetSpeed() float32 {
 	return d.Speed
 }
 
-// GetSpeedKMH returns the current speed in kilometers per hour (rounded down)
+// GetSpeedKMH returns the current speed in kilometers per hour
 func (d *ForzaHorizon5Packet) GetSpeedKMH() float32 {
-	return float32(math.Floor(float64(d.Speed * 3.6))) // 1 m/s = 3.6 km/h
+	return float32(units.MPSToKMH(float64(d.Speed)))
 }
 
-// GetSpeedMPH returns the current speed in miles per hour (rounded down)
+// GetSpeedMPH returns the current speed in miles per hour
 func (d *ForzaHorizon5Packet) GetSpeedMPH() float32 {
-	return float32(math.Floor(float64(d.Speed * 2.237))) // 1 m/s = 2.237 mph
+	return float32(units.MPSToMPH(float64(d.Speed)))
 }
 
-// GetCurrentEngineRpm returns the current engine RPM (rounded down)
+// GetCurrentEngineRpm returns the current engine RPM
 func (d *ForzaHorizon5Packet) GetCurrentEngineRpm() float32 {
-	return float32(math.Floor(float64(d.CurrentEngineRpm)))
+	return d.CurrentEngineRpm
 }
 
-// GetEngineMaxRpm returns the maximum engine RPM (rounded down)
+// GetEngineMaxRpm returns the maximum engine RPM
 func (d *ForzaHorizon5Packet) GetEngineMaxRpm() float32 {
-	return float32(math.Floor(float64(d.EngineMaxRpm)))
+	return d.EngineMaxRpm
 }
 
-// GetEngineIdleRpm returns the engine idle RPM (rounded down)
+// GetEngineIdleRpm returns the engine idle RPM
 func (d *ForzaHorizon5Packet) GetEngineIdleRpm() float32 {
-	return float32(math.Floor(float64(d.EngineIdleRpm)))
+	return d.EngineIdleRpm
 }
 
-// GetPower returns the current power output (rounded down)
+// GetPower returns the current power output in mechanical horsepower
 func (d *ForzaHorizon5Packet) GetPower() float32 {
-	return float32(math.Floor(float64(d.Power) / 745.699872)) // Convert Watts to Horsepower apparently? Thanks copilot.
+	return float32(units.WattsToHP(float64(d.Power)))
 }
 
-// GetTorque returns the current torque (rounded down)
+// GetTorque returns the current torque in N·m
 func (d *ForzaHorizon5Packet) GetTorque() float32 {
-	return float32(math.Floor(float64(d.Torque)))
+	return d.Torque
 }
 
-// GetBoost returns the current boost pressure (rounded down)
+// GetBoost returns the current boost pressure in psi
 func (d *ForzaHorizon5Packet) GetBoost() float32 {
-	return float32(math.Floor(float64(d.Boost)))
+	return d.Boost
 }
 
-// GetDistanceTraveled returns the total distance traveled (rounded down)
+// GetDistanceTraveled returns the total distance traveled in meters
 func (d *ForzaHorizon5Packet) GetDistanceTraveled() float32 {
-	return float32(math.Floor(float64(d.DistanceTraveled)))
+	return d.DistanceTraveled
 }
 
-// GetLapTimes returns best lap, last lap, and current lap times (rounded down)
+// GetLapTimes returns best lap, last lap, and current lap times in seconds
 func (d *ForzaHorizon5Packet) GetLapTimes() (float32, float32, float32) {
-	return float32(math.Floor(float64(d.BestLap))),
-		float32(math.Floor(float64(d.LastLap))),
-		float32(math.Floor(float64(d.CurrentLap)))
+	return d.BestLap, d.LastLap, d.CurrentLap
 }
 
-// GetCurrentRaceTime returns the current race time (rounded down)
+// GetCurrentRaceTime returns the current race time in seconds
 func (d *ForzaHorizon5Packet) GetCurrentRaceTime() float32 {
-	return float32(math.Floor(float64(d.CurrentRaceTime)))
+	return d.CurrentRaceTime
 }
 
 // GetAcceleration returns the X, Y, Z acceleration values
@@ -169,8 +180,8 @@ func (d *ForzaHorizon5Packet) GetAcceleration() (float32, float32, float32) {
 	return d.AccelerationX, d.AccelerationY, d.AccelerationZ
 }
 
-// GetCarClass returns the car class (0-7)
-func (d *ForzaHorizon5Packet) GetCarClass() int32 {
+// GetCarClass returns the car class, see CarClass.Name for what the game calls it
+func (d *ForzaHorizon5Packet) GetCarClass() CarClass {
 	return d.CarClass
 }
 
@@ -180,12 +191,22 @@ func (d *ForzaHorizon5Packet) GetCarPerformanceIndex() int32 {
 }
 
 // GetDrivetrainType returns the drivetrain type (FWD=0, RWD=1, AWD=2)
-func (d *ForzaHorizon5Packet) GetDrivetrainType() int32 {
+func (d *ForzaHorizon5Packet) GetDrivetrainType() Drivetrain {
 	return d.DrivetrainType
 }
 
+// GetCarType returns the car category, Horizon only
+func (d *ForzaHorizon5Packet) GetCarType() CarType {
+	return d.CarType
+}
+
+// GetCarSummary returns the class, PI and drivetrain the way the game shows them, e.g. "S1 800 AWD"
+func (d *ForzaHorizon5Packet) GetCarSummary() string {
+	return fmt.Sprintf("%s %d %s", d.CarClass.Name(d.Format), d.CarPerformanceIndex, d.DrivetrainType)
+}
+
 // GetNumCylinders returns the number of cylinders
-func (d *ForzaHorizon5Packet) GetNumCylinders() uint8 {
+func (d *ForzaHorizon5Packet) GetNumCylinders() int32 {
 	return d.NumOfCylinders
 }
 
@@ -194,11 +215,17 @@ func (d *ForzaHorizon5Packet) GetPosition() (float32, float32, float32) {
 	return d.PositionX, d.PositionY, d.PositionZ
 }
 
-// GetTireTemperatures returns all tire temperatures (FL, FR, RL, RR)
+// GetTireTemperatures returns all tire temperatures in °F (FL, FR, RL, RR)
 func (d *ForzaHorizon5Packet) GetTireTemperatures() (float32, float32, float32, float32) {
 	return d.TireTempFrontLeft, d.TireTempFrontRight, d.TireTempRearLeft, d.TireTempRearRight
 }
 
+// GetTireTemperaturesCelsius returns all tire temperatures in °C (FL, FR, RL, RR)
+func (d *ForzaHorizon5Packet) GetTireTemperaturesCelsius() (float32, float32, float32, float32) {
+	c := func(f float32) float32 { return float32(units.FahrenheitToCelsius(float64(f))) }
+	return c(d.TireTempFrontLeft), c(d.TireTempFrontRight), c(d.TireTempRearLeft), c(d.TireTempRearRight)
+}
+
 // GetLapNumber returns the current lap number
 func (d *ForzaHorizon5Packet) GetLapNumber() uint16 {
 	return d.LapNumber
@@ -214,6 +241,16 @@ func (d *ForzaHorizon5Packet) GetControls() (uint8, uint8, uint8, uint8) {
 	return d.Throttle, d.Brake, d.Clutch, d.Handbrake
 }
 
+// GetTireWear returns all tire wear values (FL, FR, RL, RR), FM 2023 only
+func (d *ForzaHorizon5Packet) GetTireWear() (float32, float32, float32, float32) {
+	return d.TireWearFrontLeft, d.TireWearFrontRight, d.TireWearRearLeft, d.TireWearRearRight
+}
+
+// GetTrackOrdinal returns the track ID, FM 2023 only
+func (d *ForzaHorizon5Packet) GetTrackOrdinal() int32 {
+	return d.TrackOrdinal
+}
+
 // GetGear returns the current gear
 func (d *ForzaHorizon5Packet) GetGear() uint8 {
 	return d.Gear
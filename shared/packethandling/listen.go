@@ -0,0 +1,222 @@
+package packethandling
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAddress = "127.0.0.1"
+	DefaultPort    = 9999
+
+	maxDatagramSize = 1024 // Bigger than any supported packet
+)
+
+// ListenConfig is one UDP socket to listen for the game on
+type ListenConfig struct {
+	Name       string // Tag for packets from this listener, defaults to the address
+	Address    string // IP to bind, 0.0.0.0 or :: for every interface
+	Port       int
+	ReadBuffer int // Socket receive buffer in bytes, 0 keeps the OS default
+}
+
+func (c ListenConfig) String() string {
+	return net.JoinHostPort(c.Address, strconv.Itoa(c.Port))
+}
+
+// SourceName is the tag packets from this listener get
+func (c ListenConfig) SourceName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return "udp://" + c.String()
+}
+
+// ParseListenConfig parses "[name=]address:port", e.g. "0.0.0.0:9999", "[::]:9999" or "fm7=:5300"
+func ParseListenConfig(s string) (ListenConfig, error) {
+	var c ListenConfig
+	if name, addr, ok := strings.Cut(s, "="); ok {
+		c.Name = name
+		s = addr
+	}
+
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return c, fmt.Errorf("invalid listen address %q: %w", s, err)
+	}
+	c.Address = host
+	if c.Port, err = strconv.Atoi(port); err != nil || c.Port < 0 || c.Port > 65535 {
+		return c, fmt.Errorf("invalid listen port %q", port)
+	}
+	return c, nil
+}
+
+// Listen opens the UDP socket for the config
+func Listen(c ListenConfig) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", c.String())
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ReadBuffer > 0 {
+		if err := conn.SetReadBuffer(c.ReadBuffer); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("setting receive buffer on %s: %w", c, err)
+		}
+	}
+	return conn, nil
+}
+
+// ListenFlags registers -addr, -port, -rcvbuf and the repeatable -listen on fs.
+// Call the returned func after parsing to get the listeners. -listen replaces the
+// -addr/-port listener unless one of those is given as well.
+func ListenFlags(fs *flag.FlagSet) func() ([]ListenConfig, error) {
+	addr := fs.String("addr", DefaultAddress, "Address to listen on, 0.0.0.0 or :: for every interface")
+	port := fs.Int("port", DefaultPort, "UDP port to listen on, set this as the Data Out port in game")
+	rcvbuf := fs.Int("rcvbuf", 0, "Socket receive buffer size in bytes, 0 keeps the OS default")
+	var listens []string
+	fs.Func("listen", "Listener as [name=]address:port instead of -addr/-port, repeat for more", func(s string) error {
+		if _, err := ParseListenConfig(s); err != nil {
+			return err
+		}
+		listens = append(listens, s)
+		return nil
+	})
+
+	return func() ([]ListenConfig, error) {
+		var configs []ListenConfig
+		explicit := false
+		fs.Visit(func(f *flag.Flag) {
+			explicit = explicit || f.Name == "addr" || f.Name == "port"
+		})
+		if len(listens) == 0 || explicit {
+			configs = append(configs, ListenConfig{Address: *addr, Port: *port, ReadBuffer: *rcvbuf})
+		}
+		for _, s := range listens {
+			c, err := ParseListenConfig(s)
+			if err != nil {
+				return nil, err
+			}
+			c.ReadBuffer = *rcvbuf
+			configs = append(configs, c)
+		}
+		return configs, nil
+	}
+}
+
+// Datagram is one packet off the wire and where it came from
+type Datagram struct {
+	Source   string // SourceName of the listener it arrived on
+	Addr     *net.UDPAddr
+	Data     []byte
+	Received time.Time
+}
+
+// MultiListener reads from several UDP sockets at once
+type MultiListener struct {
+	conns     []*net.UDPConn
+	datagrams chan Datagram
+	errs      chan error
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// ListenAll opens every listener, if any of them fail the rest are closed again
+func ListenAll(configs []ListenConfig) (*MultiListener, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("no listeners configured")
+	}
+
+	m := &MultiListener{
+		datagrams: make(chan Datagram, 64),
+		errs:      make(chan error, len(configs)),
+		done:      make(chan struct{}),
+	}
+	for _, c := range configs {
+		conn, err := Listen(c)
+		if err != nil {
+			m.Close()
+			return nil, fmt.Errorf("listening on %s: %w", c, err)
+		}
+		m.conns = append(m.conns, conn)
+	}
+
+	for i, conn := range m.conns {
+		go m.readLoop(conn, configs[i].SourceName())
+	}
+	return m, nil
+}
+
+// Conns returns the open sockets, in the same order as the configs
+func (m *MultiListener) Conns() []*net.UDPConn {
+	return m.conns
+}
+
+func (m *MultiListener) readLoop(conn *net.UDPConn, source string) {
+	for {
+		buf := make([]byte, maxDatagramSize) // Handed over to the reader, so it can't be reused
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// Anything else is passed on but the socket keeps going, e.g. Windows
+			// reports ICMP port unreachable from a relay send as a read error
+			select {
+			case m.errs <- fmt.Errorf("%s: %w", source, err):
+			case <-m.done:
+				return
+			}
+			continue
+		}
+
+		select {
+		case m.datagrams <- Datagram{Source: source, Addr: addr, Data: buf[:n], Received: time.Now()}:
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Read blocks until a datagram arrives on any listener. Returns net.ErrClosed once closed.
+func (m *MultiListener) Read() (Datagram, error) {
+	return m.ReadContext(context.Background())
+}
+
+// ReadContext is Read but gives up with the context's error when it's cancelled
+func (m *MultiListener) ReadContext(ctx context.Context) (Datagram, error) {
+	select {
+	case d := <-m.datagrams:
+		return d, nil
+	case err := <-m.errs:
+		return Datagram{}, err
+	case <-m.done:
+		return Datagram{}, net.ErrClosed
+	case <-ctx.Done():
+		return Datagram{}, ctx.Err()
+	}
+}
+
+// Close closes every socket
+func (m *MultiListener) Close() error {
+	var errs []error
+	m.closeOnce.Do(func() {
+		close(m.done)
+		for _, conn := range m.conns {
+			errs = append(errs, conn.Close())
+		}
+	})
+	return errors.Join(errs...)
+}
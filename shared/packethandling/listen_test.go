@@ -0,0 +1,54 @@
+package packethandling
+
+import (
+	"flag"
+	"slices"
+	"testing"
+)
+
+func TestListenFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"127.0.0.1:9999"}},
+		{[]string{"-port", "5300"}, []string{"127.0.0.1:5300"}},
+		{[]string{"-listen", "0.0.0.0:5300"}, []string{"0.0.0.0:5300"}},
+		{[]string{"-listen", "fm7=:5300", "-listen", "[::]:5301"}, []string{":5300", "[::]:5301"}},
+		{[]string{"-addr", "0.0.0.0", "-listen", ":5300"}, []string{"0.0.0.0:9999", ":5300"}},
+		{[]string{"-port", "9999", "-listen", ":5300"}, []string{"127.0.0.1:9999", ":5300"}},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		listeners := ListenFlags(fs)
+		if err := fs.Parse(append(tt.args, "-rcvbuf", "4096")); err != nil {
+			t.Fatal(err)
+		}
+		configs, err := listeners()
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		var got []string
+		for _, c := range configs {
+			got = append(got, c.String())
+			if c.ReadBuffer != 4096 {
+				t.Errorf("%v: %s has no -rcvbuf", tt.args, c)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParseListenConfig(t *testing.T) {
+	c, err := ParseListenConfig("fm7=[::1]:5300")
+	if err != nil || c.Name != "fm7" || c.Address != "::1" || c.Port != 5300 {
+		t.Fatalf("got %+v, %v", c, err)
+	}
+	for _, s := range []string{"", "9999", "host:port", ":65536", ":-1"} {
+		if _, err := ParseListenConfig(s); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package packethandling
+
+import "unsafe"
+
+// MarshalPacket is the inverse of ParsePacket, it encodes the packet in the
+// layout given by s.Format. A packet with no Format set, or one that isn't a
+// known format, is encoded as Horizon.
+func MarshalPacket(s *ForzaHorizon5Packet) []byte {
+	return AppendPacket(nil, s)
+}
+
+// AppendPacket encodes the packet onto the end of dst and returns the extended slice,
+// pass a reused buffer (e.g. buf[:0]) to encode without allocating
+func AppendPacket(dst []byte, s *ForzaHorizon5Packet) []byte {
+	format := s.Format
+	if format.Size() == 0 {
+		format = FormatHorizon // Also keeps plans from being indexed out of range
+	}
+
+	start := len(dst)
+	dst = append(dst, make([]byte, format.Size())...) // zeroed, so padding and unused bytes stay zero
+	packet := dst[start:]
+
+	encodePlan(packet, unsafe.Pointer(s), plans[format])
+
+	return dst
+}
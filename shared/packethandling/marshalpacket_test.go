@@ -0,0 +1,125 @@
+package packethandling
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+var allFormats = []Format{FormatMotorsport7Sled, FormatMotorsport7Dash, FormatHorizon, FormatMotorsport2023}
+
+// padding returns the bytes of a format that aren't in any field, they're always sent as zero
+func padding(format Format) []bool {
+	pad := make([]bool, format.Size())
+	for i := range pad {
+		pad[i] = true
+	}
+	for i := range fields {
+		if offset, ok := fields[i].OffsetFor(format); ok {
+			for j := range fields[i].Type.Size() {
+				pad[offset+j] = false
+			}
+		}
+	}
+	return pad
+}
+
+// Random bytes have to come back unchanged apart from the padding
+func TestMarshalRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, format := range allFormats {
+		t.Run(format.String(), func(t *testing.T) {
+			pad := padding(format)
+			for range 100 {
+				packet := make([]byte, format.Size())
+				for i := range packet {
+					if !pad[i] {
+						packet[i] = byte(rng.UintN(256))
+					}
+				}
+				checkRoundTrip(t, packet)
+			}
+		})
+	}
+}
+
+// Only Horizon has a spare byte, at the very end
+func TestPadding(t *testing.T) {
+	for _, format := range allFormats {
+		var got []int
+		for i, pad := range padding(format) {
+			if pad {
+				got = append(got, i)
+			}
+		}
+		want := []int(nil)
+		if format == FormatHorizon {
+			want = []int{323}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: padding at %v, want %v", format, got, want)
+		}
+	}
+}
+
+func FuzzMarshalRoundTrip(f *testing.F) {
+	for _, format := range allFormats {
+		f.Add(make([]byte, format.Size()))
+		f.Add(bytes.Repeat([]byte{0xff}, format.Size()))
+	}
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		format := DetectFormat(len(packet))
+		if format == FormatUnknown {
+			t.Skip()
+		}
+		for i, pad := range padding(format) {
+			if pad {
+				packet[i] = 0
+			}
+		}
+		checkRoundTrip(t, packet)
+	})
+}
+
+func checkRoundTrip(t *testing.T, packet []byte) {
+	t.Helper()
+	var p ForzaHorizon5Packet
+	if err := ParsePacket(packet, &p); err != nil {
+		t.Fatal(err)
+	}
+	out := MarshalPacket(&p)
+	if !bytes.Equal(out, packet) {
+		for i := range packet {
+			if out[i] != packet[i] {
+				t.Fatalf("%s: byte %d is %#02x after a round trip, want %#02x", p.Format, i, out[i], packet[i])
+			}
+		}
+	}
+
+	var again ForzaHorizon5Packet
+	if err := ParsePacket(out, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(MarshalPacket(&again), packet) {
+		t.Fatalf("%s: parsing the marshalled packet gives a different packet", p.Format)
+	}
+}
+
+func TestAppendPacketDefaultsToHorizon(t *testing.T) {
+	for _, format := range []Format{FormatUnknown, FormatMotorsport2023 + 1, -1} {
+		p := ForzaHorizon5Packet{Format: format, IsRaceOn: 1, LapNumber: 3}
+		b := AppendPacket([]byte{0xAA}, &p)
+		if len(b) != 1+FormatHorizon.Size() || b[0] != 0xAA {
+			t.Fatalf("format %d: got %d bytes starting %#02x, want the prefix kept and a Horizon packet after it", format, len(b), b[0])
+		}
+
+		var got ForzaHorizon5Packet
+		if err := ParsePacket(b[1:], &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Format != FormatHorizon || got.IsRaceOn != 1 || got.LapNumber != 3 {
+			t.Fatalf("format %d: got %+v", format, got)
+		}
+	}
+}
@@ -1,9 +1,8 @@
 package packethandling
 
 import (
-	"encoding/binary"
 	"fmt"
-	"math"
+	"unsafe"
 )
 
 const (
@@ -93,7 +92,7 @@ const (
 	offsetCarClass            = 216 // int32
 	offsetCarPerformanceIndex = 220 // int32
 	offsetDrivetrainType      = 224 // int32
-	offsetNumCylinders        = 228 // uint8
+	offsetNumCylinders        = 228 // int32
 	offsetCarType             = 232 // int32
 	offsetObjectHit           = 236 // int64
 
@@ -132,142 +131,25 @@ const (
 	offsetNormalizedDrivingLine = 321 // uint8
 	offsetNormalizedAIBrakeDiff = 322 // uint8
 
-	minPacketSize = 324 // Minimum required packet size
+	// Forza Motorsport (2023) only, tacked onto the end of the FM7 dash
+	offsetTireWearFL   = 311 // float32
+	offsetTireWearFR   = 315 // float32
+	offsetTireWearRL   = 319 // float32
+	offsetTireWearRR   = 323 // float32
+	offsetTrackOrdinal = 327 // int32
 )
 
+// ParsePacket decodes a datagram of any supported format into s, see Fields for the layout
 func ParsePacket(packet []byte, s *ForzaHorizon5Packet) error {
-	if len(packet) < minPacketSize {
-		return fmt.Errorf("packet too small: got %d bytes, need %d", len(packet), minPacketSize)
+	format := DetectFormat(len(packet))
+	if format == FormatUnknown {
+		return fmt.Errorf("unsupported packet size: %d bytes", len(packet))
 	}
 
-	// Basic Info
-	s.IsRaceOn = int32(binary.LittleEndian.Uint32(packet[offsetIsRaceOn:]))
-	s.TimeStampMS = binary.LittleEndian.Uint32(packet[offsetTimeStampMS:])
-	s.EngineMaxRpm = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetEngineMaxRpm:]))
-	s.EngineIdleRpm = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetEngineIdleRpm:]))
-	s.CurrentEngineRpm = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetCurrentEngineRpm:]))
-
-	// Acceleration
-	s.AccelerationX = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetAccelerationX:]))
-	s.AccelerationY = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetAccelerationY:]))
-	s.AccelerationZ = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetAccelerationZ:]))
-
-	// Velocity
-	s.VelocityX = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetVelocityX:]))
-	s.VelocityY = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetVelocityY:]))
-	s.VelocityZ = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetVelocityZ:]))
-
-	// Angular Velocity
-	s.AngularVelocityX = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetAngularVelocityX:]))
-	s.AngularVelocityY = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetAngularVelocityY:]))
-	s.AngularVelocityZ = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetAngularVelocityZ:]))
-
-	// Orientation
-	s.Yaw = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetYaw:]))
-	s.Pitch = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetPitch:]))
-	s.Roll = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetRoll:]))
-
-	// Normalized Suspension Travel
-	s.NormalizedSuspensionTravelFrontLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetNormalizedSuspensionTravelFL:]))
-	s.NormalizedSuspensionTravelFrontRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetNormalizedSuspensionTravelFR:]))
-	s.NormalizedSuspensionTravelRearLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetNormalizedSuspensionTravelRL:]))
-	s.NormalizedSuspensionTravelRearRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetNormalizedSuspensionTravelRR:]))
-
-	// Tire Slip Ratio
-	s.TireSlipRatioFrontLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireSlipRatioFL:]))
-	s.TireSlipRatioFrontRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireSlipRatioFR:]))
-	s.TireSlipRatioRearLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireSlipRatioRL:]))
-	s.TireSlipRatioRearRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireSlipRatioRR:]))
-
-	// Wheel Rotation Speed
-	s.WheelRotationSpeedFrontLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetWheelRotationSpeedFL:]))
-	s.WheelRotationSpeedFrontRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetWheelRotationSpeedFR:]))
-	s.WheelRotationSpeedRearLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetWheelRotationSpeedRL:]))
-	s.WheelRotationSpeedRearRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetWheelRotationSpeedRR:]))
-
-	// Wheel On Rumble Strip
-	s.WheelOnRumbleStripFrontLeft = int32(binary.LittleEndian.Uint32(packet[offsetWheelOnRumbleStripFL:]))
-	s.WheelOnRumbleStripFrontRight = int32(binary.LittleEndian.Uint32(packet[offsetWheelOnRumbleStripFR:]))
-	s.WheelOnRumbleStripRearLeft = int32(binary.LittleEndian.Uint32(packet[offsetWheelOnRumbleStripRL:]))
-	s.WheelOnRumbleStripRearRight = int32(binary.LittleEndian.Uint32(packet[offsetWheelOnRumbleStripRR:]))
-
-	// Wheel In Puddle
-	s.WheelInPuddleDepthFrontLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetWheelInPuddleFL:]))
-	s.WheelInPuddleDepthFrontRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetWheelInPuddleFR:]))
-	s.WheelInPuddleDepthRearLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetWheelInPuddleRL:]))
-	s.WheelInPuddleDepthRearRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetWheelInPuddleRR:]))
-
-	// Surface Rumble
-	s.SurfaceRumbleFrontLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetSurfaceRumbleFL:]))
-	s.SurfaceRumbleFrontRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetSurfaceRumbleFR:]))
-	s.SurfaceRumbleRearLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetSurfaceRumbleRL:]))
-	s.SurfaceRumbleRearRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetSurfaceRumbleRR:]))
-
-	// Tire Slip Angle
-	s.TireSlipAngleFrontLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireSlipAngleFL:]))
-	s.TireSlipAngleFrontRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireSlipAngleFR:]))
-	s.TireSlipAngleRearLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireSlipAngleRL:]))
-	s.TireSlipAngleRearRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireSlipAngleRR:]))
-
-	// Tire Combined Slip
-	s.TireCombinedSlipFrontLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireCombinedSlipFL:]))
-	s.TireCombinedSlipFrontRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireCombinedSlipFR:]))
-	s.TireCombinedSlipRearLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireCombinedSlipRL:]))
-	s.TireCombinedSlipRearRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireCombinedSlipRR:]))
-
-	// Suspension Travel Meters
-	s.SuspensionTravelMetersFrontLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetSuspensionTravelMetersFL:]))
-	s.SuspensionTravelMetersFrontRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetSuspensionTravelMetersFR:]))
-	s.SuspensionTravelMetersRearLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetSuspensionTravelMetersRL:]))
-	s.SuspensionTravelMetersRearRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetSuspensionTravelMetersRR:]))
+	// Clear out the previous packet so fields this format doesn't have end up zero
+	*s = ForzaHorizon5Packet{Format: format}
 
-	// Car Details
-	s.Ordinal = int32(binary.LittleEndian.Uint32(packet[offsetCarOrdinal:]))
-	s.CarClass = int32(binary.LittleEndian.Uint32(packet[offsetCarClass:]))
-	s.CarPerformanceIndex = int32(binary.LittleEndian.Uint32(packet[offsetCarPerformanceIndex:]))
-	s.DrivetrainType = int32(binary.LittleEndian.Uint32(packet[offsetDrivetrainType:]))
-	s.NumOfCylinders = packet[offsetNumCylinders] // Single byte
-	s.CarType = int32(binary.LittleEndian.Uint32(packet[offsetCarType:]))
-
-	// Object Hit - 64-bit value
-	s.ObjectHit = int64(binary.LittleEndian.Uint64(packet[offsetObjectHit:]))
-
-	// Position and Movement
-	s.PositionX = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetPositionX:]))
-	s.PositionY = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetPositionY:]))
-	s.PositionZ = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetPositionZ:]))
-	s.Speed = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetSpeed:]))
-	s.Power = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetPower:]))
-	s.Torque = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTorque:]))
-
-	// Tire Temperatures
-	s.TireTempFrontLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireTempFL:]))
-	s.TireTempFrontRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireTempFR:]))
-	s.TireTempRearLeft = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireTempRL:]))
-	s.TireTempRearRight = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetTireTempRR:]))
-
-	// Other Metrics
-	s.Boost = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetBoost:]))
-	s.Fuel = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetFuel:]))
-	s.DistanceTraveled = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetDistanceTraveled:]))
-	s.BestLap = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetBestLap:]))
-	s.LastLap = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetLastLap:]))
-	s.CurrentLap = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetCurrentLap:]))
-	s.CurrentRaceTime = math.Float32frombits(binary.LittleEndian.Uint32(packet[offsetCurrentRaceTime:]))
-
-	// Race Info
-	s.LapNumber = binary.LittleEndian.Uint16(packet[offsetLapNumber:])
-	s.RacePosition = packet[offsetRacePosition] // Single byte
-
-	// Controls
-	s.Throttle = packet[offsetThrottle]                                 // Single byte
-	s.Brake = packet[offsetBrake]                                       // Single byte
-	s.Clutch = packet[offsetClutch]                                     // Single byte
-	s.Handbrake = packet[offsetHandbrake]                               // Single byte
-	s.Gear = packet[offsetGear]                                         // Single byte
-	s.Steer = int8(packet[offsetSteer])                                 // Single byte
-	s.NormalizedDrivingLine = packet[offsetNormalizedDrivingLine]       // Single byte
-	s.NormalizedAIBrakeDifference = packet[offsetNormalizedAIBrakeDiff] // Single byte
+	decodePlan(packet, unsafe.Pointer(s), plans[format])
 
 	return nil
 }
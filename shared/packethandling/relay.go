@@ -0,0 +1,211 @@
+package packethandling
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// RelayFilter decides which packets a relay destination gets
+type RelayFilter int
+
+const (
+	RelayAll    RelayFilter = iota // Every datagram, even ones we can't parse
+	RelayValid                     // Parsed packets that aren't corrupted
+	RelayInRace                    // Only packets while actually racing
+)
+
+var relayFilterNames = []string{"all", "valid", "race"}
+
+func (f RelayFilter) String() string {
+	if f < 0 || int(f) >= len(relayFilterNames) {
+		return fmt.Sprintf("RelayFilter(%d)", int(f))
+	}
+	return relayFilterNames[f]
+}
+
+// RelayDestination is somewhere to forward packets to
+type RelayDestination struct {
+	Address string // host:port
+	Filter  RelayFilter
+}
+
+// ParseRelayDestination parses "host:port[,filter]" where filter is all, valid or race
+func ParseRelayDestination(s string) (RelayDestination, error) {
+	addr, filter, _ := strings.Cut(s, ",")
+	d := RelayDestination{Address: addr}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return d, fmt.Errorf("invalid relay address %q: %w", addr, err)
+	}
+
+	if filter != "" {
+		i := -1
+		for n, name := range relayFilterNames {
+			if name == filter {
+				i = n
+			}
+		}
+		if i < 0 {
+			return d, fmt.Errorf("invalid relay filter %q, want one of %s", filter, strings.Join(relayFilterNames, ", "))
+		}
+		d.Filter = RelayFilter(i)
+	}
+	return d, nil
+}
+
+// RelayFlags registers the repeatable -relay flag on fs, read the slice after parsing
+func RelayFlags(fs *flag.FlagSet) *[]RelayDestination {
+	var dests []RelayDestination
+	fs.Func("relay", "Forward every datagram to host:port[,all|valid|race], repeat for more", func(s string) error {
+		d, err := ParseRelayDestination(s)
+		if err != nil {
+			return err
+		}
+		dests = append(dests, d)
+		return nil
+	})
+	return &dests
+}
+
+// RelayStats are the counters for one destination
+type RelayStats struct {
+	Address  string
+	Filter   RelayFilter
+	Sent     uint64
+	Bytes    uint64
+	Filtered uint64 // Skipped by the filter
+	Errors   uint64
+}
+
+type relayTarget struct {
+	dest     RelayDestination
+	addr     *net.UDPAddr
+	sent     atomic.Uint64
+	bytes    atomic.Uint64
+	filtered atomic.Uint64
+	errors   atomic.Uint64
+}
+
+// Relay fans datagrams out to other tools, since the game can only send to one place
+type Relay struct {
+	conn     *net.UDPConn
+	ownsConn bool
+	targets  []*relayTarget
+}
+
+// NewRelay sends from the first of listeners, normally the sockets from
+// Setup / Listen / ListenAll so the packets come from the same port the game
+// sent to, and refuses destinations that point back at any of them. No
+// listeners opens a socket just for sending.
+func NewRelay(listeners []*net.UDPConn, dests []RelayDestination) (*Relay, error) {
+	r := &Relay{}
+	if len(listeners) > 0 {
+		r.conn = listeners[0]
+	}
+	for _, d := range dests {
+		addr, err := net.ResolveUDPAddr("udp", d.Address)
+		if err != nil {
+			return nil, fmt.Errorf("resolving relay destination %s: %w", d.Address, err)
+		}
+		for _, conn := range listeners {
+			if isSelf(conn, addr) {
+				return nil, fmt.Errorf("relay destination %s is the listener on %s", d.Address, conn.LocalAddr())
+			}
+		}
+		r.targets = append(r.targets, &relayTarget{dest: d, addr: addr})
+	}
+
+	if r.conn == nil {
+		var err error
+		if r.conn, err = net.ListenUDP("udp", nil); err != nil {
+			return nil, err
+		}
+		r.ownsConn = true
+	}
+	return r, nil
+}
+
+// interfaceAddrs is net.InterfaceAddrs, swapped out by tests
+var interfaceAddrs = net.InterfaceAddrs
+
+// isSelf catches the relay being pointed back at the socket it reads from, which would loop forever
+func isSelf(conn *net.UDPConn, addr *net.UDPAddr) bool {
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok || local.Port != addr.Port {
+		return false
+	}
+	// No IP (":9999") and 0.0.0.0 both send to this machine
+	thisMachine := addr.IP == nil || addr.IP.IsUnspecified() || addr.IP.IsLoopback()
+	if !local.IP.IsUnspecified() {
+		return local.IP.Equal(addr.IP) || (local.IP.IsLoopback() && thisMachine)
+	}
+
+	// Bound to every interface, so it's only self if the destination is one of ours
+	if thisMachine {
+		return true
+	}
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.Equal(addr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Forward sends the raw datagram to every destination whose filter lets it
+// through. p is the parsed packet, nil if it didn't parse.
+func (r *Relay) Forward(data []byte, p *ForzaHorizon5Packet) {
+	class := ClassCorrupted
+	classified := false
+
+	for _, t := range r.targets {
+		if t.dest.Filter != RelayAll {
+			if !classified && p != nil {
+				report := Validate(p)
+				class = report.Class
+				classified = true
+			}
+			if class == ClassCorrupted || (t.dest.Filter == RelayInRace && class != ClassInRace) {
+				t.filtered.Add(1)
+				continue
+			}
+		}
+
+		if _, err := r.conn.WriteToUDP(data, t.addr); err != nil {
+			t.errors.Add(1)
+			continue
+		}
+		t.sent.Add(1)
+		t.bytes.Add(uint64(len(data)))
+	}
+}
+
+// Stats returns the counters for every destination, safe to call from any goroutine
+func (r *Relay) Stats() []RelayStats {
+	stats := make([]RelayStats, len(r.targets))
+	for i, t := range r.targets {
+		stats[i] = RelayStats{
+			Address:  t.dest.Address,
+			Filter:   t.dest.Filter,
+			Sent:     t.sent.Load(),
+			Bytes:    t.bytes.Load(),
+			Filtered: t.filtered.Load(),
+			Errors:   t.errors.Load(),
+		}
+	}
+	return stats
+}
+
+// Close closes the send socket if the relay opened it, listeners passed to NewRelay are left alone
+func (r *Relay) Close() error {
+	if r.ownsConn {
+		return r.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package packethandling
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func listenUDP(t *testing.T, addr string) (*net.UDPConn, int) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(addr)})
+	if err != nil {
+		t.Skipf("can't listen on %s: %v", addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestIsSelf(t *testing.T) {
+	// A fixed set of interface addresses so the answer doesn't depend on the machine
+	saved := interfaceAddrs
+	interfaceAddrs = func() ([]net.Addr, error) {
+		return []net.Addr{
+			&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+			&net.IPNet{IP: net.ParseIP("192.168.1.20"), Mask: net.CIDRMask(24, 32)},
+		}, nil
+	}
+	t.Cleanup(func() { interfaceAddrs = saved })
+
+	every, everyPort := listenUDP(t, "0.0.0.0")
+	loopback, loopbackPort := listenUDP(t, "127.0.0.1")
+	tests := []struct {
+		name string
+		conn *net.UDPConn
+		dest string
+		want bool
+	}{
+		{"every interface, loopback", every, net.JoinHostPort("127.0.0.1", strconv.Itoa(everyPort)), true},
+		{"every interface, no host", every, net.JoinHostPort("", strconv.Itoa(everyPort)), true},
+		{"every interface, own address", every, net.JoinHostPort("192.168.1.20", strconv.Itoa(everyPort)), true},
+		{"every interface, another machine", every, net.JoinHostPort("192.168.1.30", strconv.Itoa(everyPort)), false},
+		{"every interface, another port", every, net.JoinHostPort("127.0.0.1", strconv.Itoa(everyPort+1)), false},
+		{"loopback, loopback", loopback, net.JoinHostPort("127.0.0.1", strconv.Itoa(loopbackPort)), true},
+		{"loopback, no host", loopback, net.JoinHostPort("", strconv.Itoa(loopbackPort)), true},
+		{"loopback, own LAN address", loopback, net.JoinHostPort("192.168.1.20", strconv.Itoa(loopbackPort)), false},
+		{"loopback, another machine", loopback, net.JoinHostPort("192.168.1.30", strconv.Itoa(loopbackPort)), false},
+	}
+	for _, tt := range tests {
+		addr, err := net.ResolveUDPAddr("udp", tt.dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isSelf(tt.conn, addr); got != tt.want {
+			t.Errorf("%s (%s): got %t, want %t", tt.name, tt.dest, got, tt.want)
+		}
+	}
+}
+
+func TestNewRelayRefusesSelf(t *testing.T) {
+	first, firstPort := listenUDP(t, "127.0.0.1")
+	second, secondPort := listenUDP(t, "127.0.0.1")
+	listeners := []*net.UDPConn{first, second}
+
+	for _, port := range []int{firstPort, secondPort} {
+		self := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+		if _, err := NewRelay(listeners, []RelayDestination{{Address: self}}); err == nil {
+			t.Errorf("relay to %s, one of the listeners, wasn't refused", self)
+		}
+	}
+
+	_, otherPort := listenUDP(t, "127.0.0.1")
+	r, err := NewRelay(listeners, []RelayDestination{{Address: net.JoinHostPort("127.0.0.1", strconv.Itoa(otherPort))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.conn != first {
+		t.Error("not sending from the first listener")
+	}
+}
+
+// Packets go out from the listener, and the filter counts what it skips
+func TestRelayForward(t *testing.T) {
+	conn, _ := listenUDP(t, "127.0.0.1")
+	dest, destPort := listenUDP(t, "127.0.0.1")
+	r, err := NewRelay([]*net.UDPConn{conn}, []RelayDestination{{Address: net.JoinHostPort("127.0.0.1", strconv.Itoa(destPort)), Filter: RelayInRace}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	racing := ForzaHorizon5Packet{Format: FormatHorizon, IsRaceOn: 1}
+	menu := ForzaHorizon5Packet{Format: FormatHorizon}
+	r.Forward(MarshalPacket(&menu), &menu)
+	r.Forward([]byte("not a packet"), nil)
+	r.Forward(MarshalPacket(&racing), &racing)
+
+	dest.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxDatagramSize)
+	n, from, err := dest.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != FormatHorizon.Size() || from.Port != conn.LocalAddr().(*net.UDPAddr).Port {
+		t.Fatalf("got %d bytes from %s", n, from)
+	}
+	if s := r.Stats()[0]; s.Sent != 1 || s.Filtered != 2 || s.Bytes != uint64(n) {
+		t.Fatalf("got %+v", s)
+	}
+}
@@ -0,0 +1,386 @@
+package packethandling
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"unsafe"
+)
+
+// WireType is how a field is encoded in the packet, everything is little endian
+type WireType int
+
+const (
+	TypeInt8 WireType = iota
+	TypeUint8
+	TypeUint16
+	TypeInt32
+	TypeUint32
+	TypeInt64
+	TypeFloat32
+)
+
+// Size returns the number of bytes the type takes up on the wire
+func (t WireType) Size() int {
+	switch t {
+	case TypeInt8, TypeUint8:
+		return 1
+	case TypeUint16:
+		return 2
+	case TypeInt64:
+		return 8
+	default:
+		return 4
+	}
+}
+
+// Group buckets related fields together for display and export
+type Group string
+
+const (
+	GroupRace       Group = "Race"
+	GroupEngine     Group = "Engine"
+	GroupMotion     Group = "Motion"
+	GroupSuspension Group = "Suspension"
+	GroupTires      Group = "Tires"
+	GroupWheels     Group = "Wheels"
+	GroupCar        Group = "Car"
+	GroupControls   Group = "Controls"
+)
+
+// Groups lists every group in display order
+var Groups = []Group{GroupRace, GroupCar, GroupEngine, GroupControls, GroupMotion, GroupTires, GroupWheels, GroupSuspension}
+
+// section is the part of the packet a field lives in, it decides which formats have the field
+type section int
+
+const (
+	sectionSled           section = iota // every format
+	sectionHorizon                       // Horizon only (CarType, ObjectHit)
+	sectionDash                          // every format with a dash, shifted for Motorsport
+	sectionMotorsport2023                // FM 2023 only
+)
+
+// Field describes one channel in the packet
+type Field struct {
+	Name   string   // Same as the ForzaHorizon5Packet field name
+	Offset int      // Offset in the Horizon layout (FM 2023 layout for the FM 2023 only fields)
+	Type   WireType // How it's stored on the wire
+	Unit   string   // Empty for unitless / normalized values
+	Group  Group
+
+	section section
+	at      uintptr // Offset of the matching ForzaHorizon5Packet field
+}
+
+// layout is only used to take the offsets of the packet's fields. Fields are
+// reached by offset rather than a pointer each, so parsing doesn't box anything
+// or make the packet escape.
+var layout ForzaHorizon5Packet
+
+// The one place the packet layout is written down, parsing, encoding and
+// formatting are all driven from this. Kept in wire order.
+var fields = []Field{
+	{Name: "IsRaceOn", Offset: offsetIsRaceOn, Type: TypeInt32, Group: GroupRace, section: sectionSled, at: unsafe.Offsetof(layout.IsRaceOn)},
+	{Name: "TimeStampMS", Offset: offsetTimeStampMS, Type: TypeUint32, Unit: "ms", Group: GroupRace, section: sectionSled, at: unsafe.Offsetof(layout.TimeStampMS)},
+	{Name: "EngineMaxRpm", Offset: offsetEngineMaxRpm, Type: TypeFloat32, Unit: "rpm", Group: GroupEngine, section: sectionSled, at: unsafe.Offsetof(layout.EngineMaxRpm)},
+	{Name: "EngineIdleRpm", Offset: offsetEngineIdleRpm, Type: TypeFloat32, Unit: "rpm", Group: GroupEngine, section: sectionSled, at: unsafe.Offsetof(layout.EngineIdleRpm)},
+	{Name: "CurrentEngineRpm", Offset: offsetCurrentEngineRpm, Type: TypeFloat32, Unit: "rpm", Group: GroupEngine, section: sectionSled, at: unsafe.Offsetof(layout.CurrentEngineRpm)},
+	{Name: "AccelerationX", Offset: offsetAccelerationX, Type: TypeFloat32, Unit: "m/s²", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.AccelerationX)},
+	{Name: "AccelerationY", Offset: offsetAccelerationY, Type: TypeFloat32, Unit: "m/s²", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.AccelerationY)},
+	{Name: "AccelerationZ", Offset: offsetAccelerationZ, Type: TypeFloat32, Unit: "m/s²", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.AccelerationZ)},
+	{Name: "VelocityX", Offset: offsetVelocityX, Type: TypeFloat32, Unit: "m/s", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.VelocityX)},
+	{Name: "VelocityY", Offset: offsetVelocityY, Type: TypeFloat32, Unit: "m/s", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.VelocityY)},
+	{Name: "VelocityZ", Offset: offsetVelocityZ, Type: TypeFloat32, Unit: "m/s", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.VelocityZ)},
+	{Name: "AngularVelocityX", Offset: offsetAngularVelocityX, Type: TypeFloat32, Unit: "rad/s", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.AngularVelocityX)},
+	{Name: "AngularVelocityY", Offset: offsetAngularVelocityY, Type: TypeFloat32, Unit: "rad/s", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.AngularVelocityY)},
+	{Name: "AngularVelocityZ", Offset: offsetAngularVelocityZ, Type: TypeFloat32, Unit: "rad/s", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.AngularVelocityZ)},
+	{Name: "Yaw", Offset: offsetYaw, Type: TypeFloat32, Unit: "rad", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.Yaw)},
+	{Name: "Pitch", Offset: offsetPitch, Type: TypeFloat32, Unit: "rad", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.Pitch)},
+	{Name: "Roll", Offset: offsetRoll, Type: TypeFloat32, Unit: "rad", Group: GroupMotion, section: sectionSled, at: unsafe.Offsetof(layout.Roll)},
+	{Name: "NormalizedSuspensionTravelFrontLeft", Offset: offsetNormalizedSuspensionTravelFL, Type: TypeFloat32, Group: GroupSuspension, section: sectionSled, at: unsafe.Offsetof(layout.NormalizedSuspensionTravelFrontLeft)},
+	{Name: "NormalizedSuspensionTravelFrontRight", Offset: offsetNormalizedSuspensionTravelFR, Type: TypeFloat32, Group: GroupSuspension, section: sectionSled, at: unsafe.Offsetof(layout.NormalizedSuspensionTravelFrontRight)},
+	{Name: "NormalizedSuspensionTravelRearLeft", Offset: offsetNormalizedSuspensionTravelRL, Type: TypeFloat32, Group: GroupSuspension, section: sectionSled, at: unsafe.Offsetof(layout.NormalizedSuspensionTravelRearLeft)},
+	{Name: "NormalizedSuspensionTravelRearRight", Offset: offsetNormalizedSuspensionTravelRR, Type: TypeFloat32, Group: GroupSuspension, section: sectionSled, at: unsafe.Offsetof(layout.NormalizedSuspensionTravelRearRight)},
+	{Name: "TireSlipRatioFrontLeft", Offset: offsetTireSlipRatioFL, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireSlipRatioFrontLeft)},
+	{Name: "TireSlipRatioFrontRight", Offset: offsetTireSlipRatioFR, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireSlipRatioFrontRight)},
+	{Name: "TireSlipRatioRearLeft", Offset: offsetTireSlipRatioRL, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireSlipRatioRearLeft)},
+	{Name: "TireSlipRatioRearRight", Offset: offsetTireSlipRatioRR, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireSlipRatioRearRight)},
+	{Name: "WheelRotationSpeedFrontLeft", Offset: offsetWheelRotationSpeedFL, Type: TypeFloat32, Unit: "rad/s", Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelRotationSpeedFrontLeft)},
+	{Name: "WheelRotationSpeedFrontRight", Offset: offsetWheelRotationSpeedFR, Type: TypeFloat32, Unit: "rad/s", Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelRotationSpeedFrontRight)},
+	{Name: "WheelRotationSpeedRearLeft", Offset: offsetWheelRotationSpeedRL, Type: TypeFloat32, Unit: "rad/s", Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelRotationSpeedRearLeft)},
+	{Name: "WheelRotationSpeedRearRight", Offset: offsetWheelRotationSpeedRR, Type: TypeFloat32, Unit: "rad/s", Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelRotationSpeedRearRight)},
+	{Name: "WheelOnRumbleStripFrontLeft", Offset: offsetWheelOnRumbleStripFL, Type: TypeInt32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelOnRumbleStripFrontLeft)},
+	{Name: "WheelOnRumbleStripFrontRight", Offset: offsetWheelOnRumbleStripFR, Type: TypeInt32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelOnRumbleStripFrontRight)},
+	{Name: "WheelOnRumbleStripRearLeft", Offset: offsetWheelOnRumbleStripRL, Type: TypeInt32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelOnRumbleStripRearLeft)},
+	{Name: "WheelOnRumbleStripRearRight", Offset: offsetWheelOnRumbleStripRR, Type: TypeInt32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelOnRumbleStripRearRight)},
+	{Name: "WheelInPuddleDepthFrontLeft", Offset: offsetWheelInPuddleFL, Type: TypeFloat32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelInPuddleDepthFrontLeft)},
+	{Name: "WheelInPuddleDepthFrontRight", Offset: offsetWheelInPuddleFR, Type: TypeFloat32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelInPuddleDepthFrontRight)},
+	{Name: "WheelInPuddleDepthRearLeft", Offset: offsetWheelInPuddleRL, Type: TypeFloat32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelInPuddleDepthRearLeft)},
+	{Name: "WheelInPuddleDepthRearRight", Offset: offsetWheelInPuddleRR, Type: TypeFloat32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.WheelInPuddleDepthRearRight)},
+	{Name: "SurfaceRumbleFrontLeft", Offset: offsetSurfaceRumbleFL, Type: TypeFloat32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.SurfaceRumbleFrontLeft)},
+	{Name: "SurfaceRumbleFrontRight", Offset: offsetSurfaceRumbleFR, Type: TypeFloat32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.SurfaceRumbleFrontRight)},
+	{Name: "SurfaceRumbleRearLeft", Offset: offsetSurfaceRumbleRL, Type: TypeFloat32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.SurfaceRumbleRearLeft)},
+	{Name: "SurfaceRumbleRearRight", Offset: offsetSurfaceRumbleRR, Type: TypeFloat32, Group: GroupWheels, section: sectionSled, at: unsafe.Offsetof(layout.SurfaceRumbleRearRight)},
+	{Name: "TireSlipAngleFrontLeft", Offset: offsetTireSlipAngleFL, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireSlipAngleFrontLeft)},
+	{Name: "TireSlipAngleFrontRight", Offset: offsetTireSlipAngleFR, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireSlipAngleFrontRight)},
+	{Name: "TireSlipAngleRearLeft", Offset: offsetTireSlipAngleRL, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireSlipAngleRearLeft)},
+	{Name: "TireSlipAngleRearRight", Offset: offsetTireSlipAngleRR, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireSlipAngleRearRight)},
+	{Name: "TireCombinedSlipFrontLeft", Offset: offsetTireCombinedSlipFL, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireCombinedSlipFrontLeft)},
+	{Name: "TireCombinedSlipFrontRight", Offset: offsetTireCombinedSlipFR, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireCombinedSlipFrontRight)},
+	{Name: "TireCombinedSlipRearLeft", Offset: offsetTireCombinedSlipRL, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireCombinedSlipRearLeft)},
+	{Name: "TireCombinedSlipRearRight", Offset: offsetTireCombinedSlipRR, Type: TypeFloat32, Group: GroupTires, section: sectionSled, at: unsafe.Offsetof(layout.TireCombinedSlipRearRight)},
+	{Name: "SuspensionTravelMetersFrontLeft", Offset: offsetSuspensionTravelMetersFL, Type: TypeFloat32, Unit: "m", Group: GroupSuspension, section: sectionSled, at: unsafe.Offsetof(layout.SuspensionTravelMetersFrontLeft)},
+	{Name: "SuspensionTravelMetersFrontRight", Offset: offsetSuspensionTravelMetersFR, Type: TypeFloat32, Unit: "m", Group: GroupSuspension, section: sectionSled, at: unsafe.Offsetof(layout.SuspensionTravelMetersFrontRight)},
+	{Name: "SuspensionTravelMetersRearLeft", Offset: offsetSuspensionTravelMetersRL, Type: TypeFloat32, Unit: "m", Group: GroupSuspension, section: sectionSled, at: unsafe.Offsetof(layout.SuspensionTravelMetersRearLeft)},
+	{Name: "SuspensionTravelMetersRearRight", Offset: offsetSuspensionTravelMetersRR, Type: TypeFloat32, Unit: "m", Group: GroupSuspension, section: sectionSled, at: unsafe.Offsetof(layout.SuspensionTravelMetersRearRight)},
+	{Name: "Ordinal", Offset: offsetCarOrdinal, Type: TypeInt32, Group: GroupCar, section: sectionSled, at: unsafe.Offsetof(layout.Ordinal)},
+	{Name: "CarClass", Offset: offsetCarClass, Type: TypeInt32, Group: GroupCar, section: sectionSled, at: unsafe.Offsetof(layout.CarClass)},
+	{Name: "CarPerformanceIndex", Offset: offsetCarPerformanceIndex, Type: TypeInt32, Group: GroupCar, section: sectionSled, at: unsafe.Offsetof(layout.CarPerformanceIndex)},
+	{Name: "DrivetrainType", Offset: offsetDrivetrainType, Type: TypeInt32, Group: GroupCar, section: sectionSled, at: unsafe.Offsetof(layout.DrivetrainType)},
+	{Name: "NumOfCylinders", Offset: offsetNumCylinders, Type: TypeInt32, Group: GroupCar, section: sectionSled, at: unsafe.Offsetof(layout.NumOfCylinders)},
+	{Name: "CarType", Offset: offsetCarType, Type: TypeInt32, Group: GroupCar, section: sectionHorizon, at: unsafe.Offsetof(layout.CarType)},
+	{Name: "ObjectHit", Offset: offsetObjectHit, Type: TypeInt64, Group: GroupCar, section: sectionHorizon, at: unsafe.Offsetof(layout.ObjectHit)},
+	{Name: "PositionX", Offset: offsetPositionX, Type: TypeFloat32, Unit: "m", Group: GroupMotion, section: sectionDash, at: unsafe.Offsetof(layout.PositionX)},
+	{Name: "PositionY", Offset: offsetPositionY, Type: TypeFloat32, Unit: "m", Group: GroupMotion, section: sectionDash, at: unsafe.Offsetof(layout.PositionY)},
+	{Name: "PositionZ", Offset: offsetPositionZ, Type: TypeFloat32, Unit: "m", Group: GroupMotion, section: sectionDash, at: unsafe.Offsetof(layout.PositionZ)},
+	{Name: "Speed", Offset: offsetSpeed, Type: TypeFloat32, Unit: "m/s", Group: GroupMotion, section: sectionDash, at: unsafe.Offsetof(layout.Speed)},
+	{Name: "Power", Offset: offsetPower, Type: TypeFloat32, Unit: "W", Group: GroupEngine, section: sectionDash, at: unsafe.Offsetof(layout.Power)},
+	{Name: "Torque", Offset: offsetTorque, Type: TypeFloat32, Unit: "N·m", Group: GroupEngine, section: sectionDash, at: unsafe.Offsetof(layout.Torque)},
+	{Name: "TireTempFrontLeft", Offset: offsetTireTempFL, Type: TypeFloat32, Unit: "°F", Group: GroupTires, section: sectionDash, at: unsafe.Offsetof(layout.TireTempFrontLeft)},
+	{Name: "TireTempFrontRight", Offset: offsetTireTempFR, Type: TypeFloat32, Unit: "°F", Group: GroupTires, section: sectionDash, at: unsafe.Offsetof(layout.TireTempFrontRight)},
+	{Name: "TireTempRearLeft", Offset: offsetTireTempRL, Type: TypeFloat32, Unit: "°F", Group: GroupTires, section: sectionDash, at: unsafe.Offsetof(layout.TireTempRearLeft)},
+	{Name: "TireTempRearRight", Offset: offsetTireTempRR, Type: TypeFloat32, Unit: "°F", Group: GroupTires, section: sectionDash, at: unsafe.Offsetof(layout.TireTempRearRight)},
+	{Name: "Boost", Offset: offsetBoost, Type: TypeFloat32, Unit: "psi", Group: GroupEngine, section: sectionDash, at: unsafe.Offsetof(layout.Boost)},
+	{Name: "Fuel", Offset: offsetFuel, Type: TypeFloat32, Group: GroupEngine, section: sectionDash, at: unsafe.Offsetof(layout.Fuel)},
+	{Name: "DistanceTraveled", Offset: offsetDistanceTraveled, Type: TypeFloat32, Unit: "m", Group: GroupRace, section: sectionDash, at: unsafe.Offsetof(layout.DistanceTraveled)},
+	{Name: "BestLap", Offset: offsetBestLap, Type: TypeFloat32, Unit: "s", Group: GroupRace, section: sectionDash, at: unsafe.Offsetof(layout.BestLap)},
+	{Name: "LastLap", Offset: offsetLastLap, Type: TypeFloat32, Unit: "s", Group: GroupRace, section: sectionDash, at: unsafe.Offsetof(layout.LastLap)},
+	{Name: "CurrentLap", Offset: offsetCurrentLap, Type: TypeFloat32, Unit: "s", Group: GroupRace, section: sectionDash, at: unsafe.Offsetof(layout.CurrentLap)},
+	{Name: "CurrentRaceTime", Offset: offsetCurrentRaceTime, Type: TypeFloat32, Unit: "s", Group: GroupRace, section: sectionDash, at: unsafe.Offsetof(layout.CurrentRaceTime)},
+	{Name: "LapNumber", Offset: offsetLapNumber, Type: TypeUint16, Group: GroupRace, section: sectionDash, at: unsafe.Offsetof(layout.LapNumber)},
+	{Name: "RacePosition", Offset: offsetRacePosition, Type: TypeUint8, Group: GroupRace, section: sectionDash, at: unsafe.Offsetof(layout.RacePosition)},
+	{Name: "Throttle", Offset: offsetThrottle, Type: TypeUint8, Group: GroupControls, section: sectionDash, at: unsafe.Offsetof(layout.Throttle)},
+	{Name: "Brake", Offset: offsetBrake, Type: TypeUint8, Group: GroupControls, section: sectionDash, at: unsafe.Offsetof(layout.Brake)},
+	{Name: "Clutch", Offset: offsetClutch, Type: TypeUint8, Group: GroupControls, section: sectionDash, at: unsafe.Offsetof(layout.Clutch)},
+	{Name: "Handbrake", Offset: offsetHandbrake, Type: TypeUint8, Group: GroupControls, section: sectionDash, at: unsafe.Offsetof(layout.Handbrake)},
+	{Name: "Gear", Offset: offsetGear, Type: TypeUint8, Group: GroupControls, section: sectionDash, at: unsafe.Offsetof(layout.Gear)},
+	{Name: "Steer", Offset: offsetSteer, Type: TypeInt8, Group: GroupControls, section: sectionDash, at: unsafe.Offsetof(layout.Steer)},
+	{Name: "NormalizedDrivingLine", Offset: offsetNormalizedDrivingLine, Type: TypeUint8, Group: GroupControls, section: sectionDash, at: unsafe.Offsetof(layout.NormalizedDrivingLine)},
+	{Name: "NormalizedAIBrakeDifference", Offset: offsetNormalizedAIBrakeDiff, Type: TypeUint8, Group: GroupControls, section: sectionDash, at: unsafe.Offsetof(layout.NormalizedAIBrakeDifference)},
+	{Name: "TireWearFrontLeft", Offset: offsetTireWearFL, Type: TypeFloat32, Group: GroupTires, section: sectionMotorsport2023, at: unsafe.Offsetof(layout.TireWearFrontLeft)},
+	{Name: "TireWearFrontRight", Offset: offsetTireWearFR, Type: TypeFloat32, Group: GroupTires, section: sectionMotorsport2023, at: unsafe.Offsetof(layout.TireWearFrontRight)},
+	{Name: "TireWearRearLeft", Offset: offsetTireWearRL, Type: TypeFloat32, Group: GroupTires, section: sectionMotorsport2023, at: unsafe.Offsetof(layout.TireWearRearLeft)},
+	{Name: "TireWearRearRight", Offset: offsetTireWearRR, Type: TypeFloat32, Group: GroupTires, section: sectionMotorsport2023, at: unsafe.Offsetof(layout.TireWearRearRight)},
+	{Name: "TrackOrdinal", Offset: offsetTrackOrdinal, Type: TypeInt32, Group: GroupRace, section: sectionMotorsport2023, at: unsafe.Offsetof(layout.TrackOrdinal)},
+}
+
+var fieldsByName = func() map[string]*Field {
+	m := make(map[string]*Field, len(fields))
+	for i := range fields {
+		m[fields[i].Name] = &fields[i]
+	}
+	return m
+}()
+
+// Fields returns every field in wire order. Don't modify the returned slice.
+func Fields() []Field {
+	return fields
+}
+
+// LookupField finds a field by its name, e.g. "TireTempFrontLeft"
+func LookupField(name string) (*Field, bool) {
+	f, ok := fieldsByName[name]
+	return f, ok
+}
+
+// OffsetFor returns where the field is in the given format, false if the format doesn't send it
+func (f *Field) OffsetFor(format Format) (int, bool) {
+	switch f.section {
+	case sectionSled:
+		return f.Offset, format != FormatUnknown
+	case sectionHorizon:
+		return f.Offset, format == FormatHorizon
+	case sectionDash:
+		return f.Offset - format.dashShift(), format.HasDash()
+	case sectionMotorsport2023:
+		return f.Offset, format == FormatMotorsport2023
+	default:
+		return 0, false
+	}
+}
+
+// step is one field of a format's decode plan, or a run of fields of the same
+// size that sit back to back in both the packet and the struct
+type step struct {
+	wire int     // Offset in the packet
+	at   uintptr // Offset in ForzaHorizon5Packet
+	typ  WireType
+	run  int // Bytes in the run, 0 for a single field of typ
+}
+
+// plans lists the fields each format has, so parsing and encoding don't have
+// to work out the offsets field by field
+var plans = func() (plans [FormatMotorsport2023 + 1][]step) {
+	for format := range plans {
+		var plan []step
+		for i := range fields {
+			f := &fields[i]
+			offset, ok := f.OffsetFor(Format(format))
+			if !ok {
+				continue
+			}
+			// 8 and 32 bit fields are copied as bits whatever their type
+			size := f.Type.Size()
+			if size != 1 && size != 4 {
+				plan = append(plan, step{wire: offset, at: f.at, typ: f.Type})
+				continue
+			}
+			if n := len(plan); n > 0 {
+				last := &plan[n-1]
+				if last.run > 0 && last.typ.Size() == size &&
+					offset == last.wire+last.run && f.at == last.at+uintptr(last.run) {
+					last.run += size
+					continue
+				}
+			}
+			typ := TypeUint8
+			if size == 4 {
+				typ = TypeUint32
+			}
+			plan = append(plan, step{wire: offset, at: f.at, typ: typ, run: size})
+		}
+		plans[format] = plan
+	}
+	return plans
+}()
+
+// littleEndian is true if runs can be copied straight between the packet and the struct
+var littleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// decodePlan reads every field of the plan out of the packet into the struct at base
+func decodePlan(packet []byte, base unsafe.Pointer, plan []step) {
+	for _, st := range plan {
+		p := unsafe.Add(base, st.at)
+		switch {
+		case st.run == 0:
+			decode(packet[st.wire:], p, st.typ)
+		case littleEndian || st.typ == TypeUint8:
+			copy(unsafe.Slice((*byte)(p), st.run), packet[st.wire:])
+		default:
+			b := packet[st.wire : st.wire+st.run]
+			for ; len(b) >= 4; b = b[4:] {
+				*(*uint32)(p) = binary.LittleEndian.Uint32(b)
+				p = unsafe.Add(p, 4)
+			}
+		}
+	}
+}
+
+// encodePlan writes every field of the plan from the struct at base into the packet
+func encodePlan(packet []byte, base unsafe.Pointer, plan []step) {
+	for _, st := range plan {
+		p := unsafe.Add(base, st.at)
+		switch {
+		case st.run == 0:
+			encode(packet[st.wire:], p, st.typ)
+		case littleEndian || st.typ == TypeUint8:
+			copy(packet[st.wire:st.wire+st.run], unsafe.Slice((*byte)(p), st.run))
+		default:
+			b := packet[st.wire : st.wire+st.run]
+			for ; len(b) >= 4; b = b[4:] {
+				binary.LittleEndian.PutUint32(b, *(*uint32)(p))
+				p = unsafe.Add(p, 4)
+			}
+		}
+	}
+}
+
+// Get returns the field's value from the packet as a float64
+func (f *Field) Get(s *ForzaHorizon5Packet) float64 {
+	p := unsafe.Add(unsafe.Pointer(s), f.at)
+	switch f.Type {
+	case TypeInt8:
+		return float64(*(*int8)(p))
+	case TypeUint8:
+		return float64(*(*uint8)(p))
+	case TypeUint16:
+		return float64(*(*uint16)(p))
+	case TypeInt32:
+		return float64(*(*int32)(p))
+	case TypeUint32:
+		return float64(*(*uint32)(p))
+	case TypeInt64:
+		return float64(*(*int64)(p))
+	case TypeFloat32:
+		return float64(*(*float32)(p))
+	default:
+		return 0
+	}
+}
+
+// String formats the field's value from the packet without any float64 noise
+func (f *Field) String(s *ForzaHorizon5Packet) string {
+	p := unsafe.Add(unsafe.Pointer(s), f.at)
+	switch f.Type {
+	case TypeFloat32:
+		return strconv.FormatFloat(float64(*(*float32)(p)), 'f', -1, 32)
+	case TypeInt64:
+		return strconv.FormatInt(*(*int64)(p), 10)
+	default:
+		return strconv.FormatFloat(f.Get(s), 'f', -1, 64)
+	}
+}
+
+// decode reads a field out of b (already sliced to its offset) into the struct field at p
+func decode(b []byte, p unsafe.Pointer, typ WireType) {
+	switch typ {
+	case TypeInt8:
+		*(*int8)(p) = int8(b[0])
+	case TypeUint8:
+		*(*uint8)(p) = b[0]
+	case TypeUint16:
+		*(*uint16)(p) = binary.LittleEndian.Uint16(b)
+	case TypeInt32:
+		*(*int32)(p) = int32(binary.LittleEndian.Uint32(b))
+	case TypeUint32:
+		*(*uint32)(p) = binary.LittleEndian.Uint32(b)
+	case TypeInt64:
+		*(*int64)(p) = int64(binary.LittleEndian.Uint64(b))
+	case TypeFloat32:
+		*(*float32)(p) = math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+}
+
+// encode writes the struct field at p into b (already sliced to its offset)
+func encode(b []byte, p unsafe.Pointer, typ WireType) {
+	switch typ {
+	case TypeInt8:
+		b[0] = byte(*(*int8)(p))
+	case TypeUint8:
+		b[0] = *(*uint8)(p)
+	case TypeUint16:
+		binary.LittleEndian.PutUint16(b, *(*uint16)(p))
+	case TypeInt32:
+		binary.LittleEndian.PutUint32(b, uint32(*(*int32)(p)))
+	case TypeUint32:
+		binary.LittleEndian.PutUint32(b, *(*uint32)(p))
+	case TypeInt64:
+		binary.LittleEndian.PutUint64(b, uint64(*(*int64)(p)))
+	case TypeFloat32:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(*(*float32)(p)))
+	}
+}
+
+// Field reads a value by name, e.g. packet.Field("TireTempFrontLeft").
+// Returns false if there's no such field or the packet's format doesn't have it.
+func (d *ForzaHorizon5Packet) Field(name string) (float64, bool) {
+	f, ok := LookupField(name)
+	if !ok {
+		return 0, false
+	}
+	if _, ok := f.OffsetFor(d.Format); !ok {
+		return 0, false
+	}
+	return f.Get(d), true
+}
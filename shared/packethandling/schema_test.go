@@ -0,0 +1,220 @@
+package packethandling
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The layouts as the game documents them, in wire order. Offsets are worked out
+// from these sizes alone so they check the offset constants rather than repeat them.
+var (
+	specSled = []string{
+		"s32 IsRaceOn",
+		"u32 TimeStampMS",
+		"f32 EngineMaxRpm",
+		"f32 EngineIdleRpm",
+		"f32 CurrentEngineRpm",
+		"f32 AccelerationX",
+		"f32 AccelerationY",
+		"f32 AccelerationZ",
+		"f32 VelocityX",
+		"f32 VelocityY",
+		"f32 VelocityZ",
+		"f32 AngularVelocityX",
+		"f32 AngularVelocityY",
+		"f32 AngularVelocityZ",
+		"f32 Yaw",
+		"f32 Pitch",
+		"f32 Roll",
+		"f32 NormalizedSuspensionTravelFrontLeft",
+		"f32 NormalizedSuspensionTravelFrontRight",
+		"f32 NormalizedSuspensionTravelRearLeft",
+		"f32 NormalizedSuspensionTravelRearRight",
+		"f32 TireSlipRatioFrontLeft",
+		"f32 TireSlipRatioFrontRight",
+		"f32 TireSlipRatioRearLeft",
+		"f32 TireSlipRatioRearRight",
+		"f32 WheelRotationSpeedFrontLeft",
+		"f32 WheelRotationSpeedFrontRight",
+		"f32 WheelRotationSpeedRearLeft",
+		"f32 WheelRotationSpeedRearRight",
+		"s32 WheelOnRumbleStripFrontLeft",
+		"s32 WheelOnRumbleStripFrontRight",
+		"s32 WheelOnRumbleStripRearLeft",
+		"s32 WheelOnRumbleStripRearRight",
+		"f32 WheelInPuddleDepthFrontLeft",
+		"f32 WheelInPuddleDepthFrontRight",
+		"f32 WheelInPuddleDepthRearLeft",
+		"f32 WheelInPuddleDepthRearRight",
+		"f32 SurfaceRumbleFrontLeft",
+		"f32 SurfaceRumbleFrontRight",
+		"f32 SurfaceRumbleRearLeft",
+		"f32 SurfaceRumbleRearRight",
+		"f32 TireSlipAngleFrontLeft",
+		"f32 TireSlipAngleFrontRight",
+		"f32 TireSlipAngleRearLeft",
+		"f32 TireSlipAngleRearRight",
+		"f32 TireCombinedSlipFrontLeft",
+		"f32 TireCombinedSlipFrontRight",
+		"f32 TireCombinedSlipRearLeft",
+		"f32 TireCombinedSlipRearRight",
+		"f32 SuspensionTravelMetersFrontLeft",
+		"f32 SuspensionTravelMetersFrontRight",
+		"f32 SuspensionTravelMetersRearLeft",
+		"f32 SuspensionTravelMetersRearRight",
+		"s32 Ordinal",
+		"s32 CarClass",
+		"s32 CarPerformanceIndex",
+		"s32 DrivetrainType",
+		"s32 NumOfCylinders",
+	}
+	specHorizon = []string{
+		"s32 CarType",
+		"s64 ObjectHit",
+	}
+	specDash = []string{
+		"f32 PositionX",
+		"f32 PositionY",
+		"f32 PositionZ",
+		"f32 Speed",
+		"f32 Power",
+		"f32 Torque",
+		"f32 TireTempFrontLeft",
+		"f32 TireTempFrontRight",
+		"f32 TireTempRearLeft",
+		"f32 TireTempRearRight",
+		"f32 Boost",
+		"f32 Fuel",
+		"f32 DistanceTraveled",
+		"f32 BestLap",
+		"f32 LastLap",
+		"f32 CurrentLap",
+		"f32 CurrentRaceTime",
+		"u16 LapNumber",
+		"u8 RacePosition",
+		"u8 Throttle",
+		"u8 Brake",
+		"u8 Clutch",
+		"u8 Handbrake",
+		"u8 Gear",
+		"s8 Steer",
+		"u8 NormalizedDrivingLine",
+		"u8 NormalizedAIBrakeDifference",
+	}
+	specMotorsport2023 = []string{
+		"f32 TireWearFrontLeft",
+		"f32 TireWearFrontRight",
+		"f32 TireWearRearLeft",
+		"f32 TireWearRearRight",
+		"s32 TrackOrdinal",
+	}
+)
+
+var specSizes = map[string]int{"s8": 1, "u8": 1, "u16": 2, "s32": 4, "u32": 4, "f32": 4, "s64": 8}
+
+var specTypes = map[string]WireType{
+	"s8": TypeInt8, "u8": TypeUint8, "u16": TypeUint16, "s32": TypeInt32,
+	"u32": TypeUint32, "f32": TypeFloat32, "s64": TypeInt64,
+}
+
+type specField struct {
+	typ    string
+	offset int
+}
+
+// specLayout lays the sections out back to back, padding is added on the end
+func specLayout(sections ...[]string) (map[string]specField, int) {
+	layout := map[string]specField{}
+	offset := 0
+	for _, section := range sections {
+		for _, line := range section {
+			typ, name, _ := strings.Cut(line, " ")
+			layout[name] = specField{typ, offset}
+			offset += specSizes[typ]
+		}
+	}
+	return layout, offset
+}
+
+func TestFieldOffsets(t *testing.T) {
+	tests := []struct {
+		format   Format
+		sections [][]string
+		padding  int
+	}{
+		{FormatMotorsport7Sled, [][]string{specSled}, 0},
+		{FormatMotorsport7Dash, [][]string{specSled, specDash}, 0},
+		{FormatHorizon, [][]string{specSled, specHorizon, specDash}, 1},
+		{FormatMotorsport2023, [][]string{specSled, specDash, specMotorsport2023}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			want, size := specLayout(tt.sections...)
+			if size+tt.padding != tt.format.Size() {
+				t.Fatalf("spec adds up to %d bytes plus %d padding, format is %d", size, tt.padding, tt.format.Size())
+			}
+
+			for i := range fields {
+				f := &fields[i]
+				offset, ok := f.OffsetFor(tt.format)
+				w, inSpec := want[f.Name]
+				switch {
+				case ok != inSpec:
+					t.Errorf("%s: in format %t, in spec %t", f.Name, ok, inSpec)
+				case !ok:
+				case offset != w.offset:
+					t.Errorf("%s: offset %d, spec says %d", f.Name, offset, w.offset)
+				case f.Type != specTypes[w.typ]:
+					t.Errorf("%s: type %d, spec says %s", f.Name, f.Type, w.typ)
+				}
+				delete(want, f.Name)
+			}
+			for name := range want {
+				t.Errorf("%s is in the spec but not the table", name)
+			}
+		})
+	}
+}
+
+// Fields are reached by offset, so each has to point at a struct field of the same size
+func TestFieldStructOffsets(t *testing.T) {
+	typ := reflect.TypeOf(ForzaHorizon5Packet{})
+	for i := range fields {
+		f := &fields[i]
+		sf, ok := typ.FieldByName(f.Name)
+		if !ok {
+			t.Errorf("%s: no such struct field", f.Name)
+			continue
+		}
+		if sf.Offset != f.at {
+			t.Errorf("%s: at %d, struct field is at %d", f.Name, f.at, sf.Offset)
+		}
+		if int(sf.Type.Size()) != f.Type.Size() {
+			t.Errorf("%s: %d byte struct field for a %d byte wire field", f.Name, sf.Type.Size(), f.Type.Size())
+		}
+		signed := sf.Type.Kind() >= reflect.Int && sf.Type.Kind() <= reflect.Int64
+		if f.Type == TypeFloat32 && sf.Type.Kind() != reflect.Float32 ||
+			(f.Type == TypeInt8 || f.Type == TypeInt32 || f.Type == TypeInt64) && !signed {
+			t.Errorf("%s: %s struct field for wire type %d", f.Name, sf.Type, f.Type)
+		}
+	}
+}
+
+func TestFieldGet(t *testing.T) {
+	p := ForzaHorizon5Packet{Format: FormatHorizon, Steer: -127, ObjectHit: 1 << 40, LapNumber: 7, Speed: 12.5, NumOfCylinders: 8}
+	for name, want := range map[string]float64{"Steer": -127, "ObjectHit": 1 << 40, "LapNumber": 7, "Speed": 12.5, "NumOfCylinders": 8} {
+		if got, ok := p.Field(name); !ok || got != want {
+			t.Errorf("%s: got %v %t, want %v", name, got, ok, want)
+		}
+	}
+	if _, ok := (&ForzaHorizon5Packet{Format: FormatMotorsport7Sled}).Field("Speed"); ok {
+		t.Error("Speed shouldn't be there in a sled packet")
+	}
+
+	f, _ := LookupField("Speed")
+	if got := f.String(&p); got != "12.5" {
+		t.Errorf("String: got %q", got)
+	}
+}
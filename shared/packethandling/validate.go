@@ -0,0 +1,168 @@
+package packethandling
+
+import (
+	"fmt"
+	"math"
+)
+
+// Severity says how bad a validation issue is
+type Severity int
+
+const (
+	SeverityWarning Severity = iota // Suspicious but could be real
+	SeverityError                   // Can't be real, the packet is garbage
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Class is the overall verdict on a packet
+type Class int
+
+const (
+	ClassInRace    Class = iota // Driving, values can be trusted
+	ClassPaused                 // Paused or in a menu, FH5 sends all zeros here
+	ClassCorrupted              // Has at least one hard error
+)
+
+func (c Class) String() string {
+	switch c {
+	case ClassInRace:
+		return "In Race"
+	case ClassPaused:
+		return "Paused / Menu"
+	case ClassCorrupted:
+		return "Corrupted"
+	default:
+		return fmt.Sprintf("Class(%d)", int(c))
+	}
+}
+
+// Issue is one problem found in a packet
+type Issue struct {
+	Field    string
+	Severity Severity
+	Message  string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Field, i.Message)
+}
+
+// ValidationReport is the result of Validate
+type ValidationReport struct {
+	Class  Class
+	Issues []Issue
+}
+
+// HasErrors returns true if any issue is a hard error
+func (r *ValidationReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	maxGear           = 11  // 0 is reverse, some games use 11 for neutral
+	maxDrivetrainType = 2   // AWD
+	maxPlausibleSpeed = 250 // m/s, 900 km/h is past anything in the games
+	rpmTolerance      = 1.01
+)
+
+// Validate sanity checks a parsed packet and classifies it. ParsePacket only
+// checks the size, so run this before trusting the values.
+func Validate(p *ForzaHorizon5Packet) ValidationReport {
+	var r ValidationReport
+	add := func(field string, severity Severity, format string, args ...any) {
+		r.Issues = append(r.Issues, Issue{Field: field, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if p.Format == FormatUnknown {
+		add("Format", SeverityError, "unknown packet format")
+	}
+
+	// NaN / Inf never come out of a real physics tick
+	for i := range fields {
+		f := &fields[i]
+		if f.Type != TypeFloat32 {
+			continue
+		}
+		if _, ok := f.OffsetFor(p.Format); !ok {
+			continue
+		}
+		if v := f.Get(p); math.IsNaN(v) || math.IsInf(v, 0) {
+			add(f.Name, SeverityError, "not a number: %v", v)
+		}
+	}
+
+	if p.IsRaceOn != 0 && p.IsRaceOn != 1 {
+		add("IsRaceOn", SeverityError, "expected 0 or 1, got %d", p.IsRaceOn)
+	}
+
+	// Engine
+	if p.EngineMaxRpm < 0 {
+		add("EngineMaxRpm", SeverityError, "negative: %v", p.EngineMaxRpm)
+	}
+	if p.CurrentEngineRpm < 0 {
+		add("CurrentEngineRpm", SeverityError, "negative: %v", p.CurrentEngineRpm)
+	}
+	if p.EngineMaxRpm > 0 && p.CurrentEngineRpm > p.EngineMaxRpm*rpmTolerance {
+		add("CurrentEngineRpm", SeverityError, "%v is above EngineMaxRpm %v", p.CurrentEngineRpm, p.EngineMaxRpm)
+	}
+	if p.EngineMaxRpm > 0 && p.EngineIdleRpm > p.EngineMaxRpm {
+		add("EngineIdleRpm", SeverityWarning, "%v is above EngineMaxRpm %v", p.EngineIdleRpm, p.EngineMaxRpm)
+	}
+
+	// Car
+	if !p.CarClass.valid(p.Format) {
+		add("CarClass", SeverityWarning, "out of range for %s: %d", p.Format, p.CarClass)
+	}
+	if p.DrivetrainType < 0 || p.DrivetrainType > maxDrivetrainType {
+		add("DrivetrainType", SeverityWarning, "out of range: %d", p.DrivetrainType)
+	}
+	if p.CarPerformanceIndex < 0 || p.CarPerformanceIndex > 999 {
+		add("CarPerformanceIndex", SeverityWarning, "out of range: %d", p.CarPerformanceIndex)
+	}
+
+	// Suspension travel is normalized, 0 is fully extended and 1 is max compression
+	for _, name := range []string{"NormalizedSuspensionTravelFrontLeft", "NormalizedSuspensionTravelFrontRight", "NormalizedSuspensionTravelRearLeft", "NormalizedSuspensionTravelRearRight"} {
+		if v, _ := p.Field(name); v < 0 || v > 1 {
+			add(name, SeverityWarning, "outside 0-1: %v", v)
+		}
+	}
+
+	if p.Format.HasDash() {
+		if p.Speed < 0 || p.Speed > maxPlausibleSpeed {
+			add("Speed", SeverityWarning, "implausible speed: %v m/s", p.Speed)
+		}
+		if p.Fuel < 0 || p.Fuel > 1 {
+			add("Fuel", SeverityWarning, "outside 0-1: %v", p.Fuel)
+		}
+		if p.Gear > maxGear {
+			add("Gear", SeverityError, "no such gear: %d", p.Gear)
+		}
+		for _, name := range []string{"BestLap", "LastLap", "CurrentLap", "CurrentRaceTime"} {
+			if v, _ := p.Field(name); v < 0 {
+				add(name, SeverityWarning, "negative time: %v", v)
+			}
+		}
+	}
+
+	switch {
+	case r.HasErrors():
+		r.Class = ClassCorrupted
+	case p.IsRaceOn == 0:
+		r.Class = ClassPaused
+	default:
+		r.Class = ClassInRace
+	}
+
+	return r
+}
@@ -0,0 +1,89 @@
+package packethandling
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// racing returns a plausible Horizon packet mid race
+func racing() ForzaHorizon5Packet {
+	return ForzaHorizon5Packet{
+		Format:           FormatHorizon,
+		IsRaceOn:         1,
+		EngineMaxRpm:     8000,
+		EngineIdleRpm:    900,
+		CurrentEngineRpm: 6000,
+		CarClass:         3,
+		DrivetrainType:   1,
+		Speed:            40,
+		Fuel:             0.5,
+		Gear:             3,
+		CurrentLap:       12.5,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(p *ForzaHorizon5Packet)
+		class  Class
+		issues []string // Fields with an issue
+	}{
+		{"racing", func(p *ForzaHorizon5Packet) {}, ClassInRace, nil},
+		{"zeroed in a menu", func(p *ForzaHorizon5Packet) { *p = ForzaHorizon5Packet{Format: FormatHorizon} }, ClassPaused, nil},
+		{"zeroed sled in a menu", func(p *ForzaHorizon5Packet) { *p = ForzaHorizon5Packet{Format: FormatMotorsport7Sled} }, ClassPaused, nil},
+		{"zeroed FM2023 in a menu", func(p *ForzaHorizon5Packet) { *p = ForzaHorizon5Packet{Format: FormatMotorsport2023} }, ClassPaused, nil},
+		{"unknown format", func(p *ForzaHorizon5Packet) { p.Format = FormatUnknown }, ClassCorrupted, []string{"Format"}},
+		{"IsRaceOn not a bool", func(p *ForzaHorizon5Packet) { p.IsRaceOn = 2 }, ClassCorrupted, []string{"IsRaceOn"}},
+		{"NaN", func(p *ForzaHorizon5Packet) { p.AccelerationX = float32(math.NaN()) }, ClassCorrupted, []string{"AccelerationX"}},
+		{"Inf", func(p *ForzaHorizon5Packet) { p.Speed = float32(math.Inf(1)) }, ClassCorrupted, []string{"Speed", "Speed"}},
+
+		{"rpm at max", func(p *ForzaHorizon5Packet) { p.CurrentEngineRpm = 8000 }, ClassInRace, nil},
+		{"rpm within tolerance", func(p *ForzaHorizon5Packet) { p.CurrentEngineRpm = 8079 }, ClassInRace, nil},
+		{"rpm past tolerance", func(p *ForzaHorizon5Packet) { p.CurrentEngineRpm = 8081 }, ClassCorrupted, []string{"CurrentEngineRpm"}},
+		{"negative rpm", func(p *ForzaHorizon5Packet) { p.CurrentEngineRpm = -1 }, ClassCorrupted, []string{"CurrentEngineRpm"}},
+		{"negative max rpm", func(p *ForzaHorizon5Packet) { p.EngineMaxRpm = -1 }, ClassCorrupted, []string{"EngineMaxRpm"}},
+		{"idle above max", func(p *ForzaHorizon5Packet) { p.EngineIdleRpm = 8001 }, ClassInRace, []string{"EngineIdleRpm"}},
+
+		{"class D", func(p *ForzaHorizon5Packet) { p.CarClass = 0 }, ClassInRace, nil},
+		{"class X", func(p *ForzaHorizon5Packet) { p.CarClass = CarClassX }, ClassInRace, nil},
+		{"class past X", func(p *ForzaHorizon5Packet) { p.CarClass = CarClassX + 1 }, ClassInRace, []string{"CarClass"}},
+		{"FM2023 class X", func(p *ForzaHorizon5Packet) { p.Format, p.CarClass = FormatMotorsport2023, 8 }, ClassInRace, nil},
+		{"FM7 class past X", func(p *ForzaHorizon5Packet) { p.Format, p.CarClass = FormatMotorsport7Dash, 8 }, ClassInRace, []string{"CarClass"}},
+		{"negative class", func(p *ForzaHorizon5Packet) { p.CarClass = -1 }, ClassInRace, []string{"CarClass"}},
+		{"AWD", func(p *ForzaHorizon5Packet) { p.DrivetrainType = maxDrivetrainType }, ClassInRace, nil},
+		{"drivetrain past AWD", func(p *ForzaHorizon5Packet) { p.DrivetrainType = maxDrivetrainType + 1 }, ClassInRace, []string{"DrivetrainType"}},
+		{"PI 999", func(p *ForzaHorizon5Packet) { p.CarPerformanceIndex = 999 }, ClassInRace, nil},
+		{"PI 1000", func(p *ForzaHorizon5Packet) { p.CarPerformanceIndex = 1000 }, ClassInRace, []string{"CarPerformanceIndex"}},
+
+		{"suspension fully compressed", func(p *ForzaHorizon5Packet) { p.NormalizedSuspensionTravelRearLeft = 1 }, ClassInRace, nil},
+		{"suspension past 1", func(p *ForzaHorizon5Packet) { p.NormalizedSuspensionTravelRearLeft = 1.01 }, ClassInRace, []string{"NormalizedSuspensionTravelRearLeft"}},
+
+		{"top speed", func(p *ForzaHorizon5Packet) { p.Speed = maxPlausibleSpeed }, ClassInRace, nil},
+		{"past top speed", func(p *ForzaHorizon5Packet) { p.Speed = maxPlausibleSpeed + 1 }, ClassInRace, []string{"Speed"}},
+		{"full tank", func(p *ForzaHorizon5Packet) { p.Fuel = 1 }, ClassInRace, nil},
+		{"overfull tank", func(p *ForzaHorizon5Packet) { p.Fuel = 1.5 }, ClassInRace, []string{"Fuel"}},
+		{"top gear", func(p *ForzaHorizon5Packet) { p.Gear = maxGear }, ClassInRace, nil},
+		{"no such gear", func(p *ForzaHorizon5Packet) { p.Gear = maxGear + 1 }, ClassCorrupted, []string{"Gear"}},
+		{"negative lap time", func(p *ForzaHorizon5Packet) { p.LastLap = -1 }, ClassInRace, []string{"LastLap"}},
+
+		// Sled packets have no dash, so dash fields aren't checked
+		{"sled ignores dash", func(p *ForzaHorizon5Packet) { p.Format = FormatMotorsport7Sled; p.Gear = 200; p.Speed = -1 }, ClassInRace, nil},
+		{"paused with an error", func(p *ForzaHorizon5Packet) { p.IsRaceOn = 0; p.Gear = 200 }, ClassCorrupted, []string{"Gear"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := racing()
+			tt.edit(&p)
+			r := Validate(&p)
+			var got []string
+			for _, issue := range r.Issues {
+				got = append(got, issue.Field)
+			}
+			if r.Class != tt.class || !slices.Equal(got, tt.issues) {
+				t.Errorf("got %s with %v, want %s with %v", r.Class, r.Issues, tt.class, tt.issues)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+// Package pcap reads Wireshark / tcpdump captures (pcap and pcapng) and pulls
+// the UDP payloads out of them, just enough to get Forza packets back out.
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"time"
+)
+
+// Link types we know how to unwrap, see https://www.tcpdump.org/linktypes.html
+const (
+	LinkTypeNull      = 0
+	LinkTypeEthernet  = 1
+	LinkTypeRaw       = 101
+	LinkTypeLoop      = 108
+	LinkTypeLinuxSLL  = 113
+	LinkTypeIPv4      = 228
+	LinkTypeIPv6      = 229
+	LinkTypeLinuxSLL2 = 276
+)
+
+const (
+	magicMicros        = 0xa1b2c3d4
+	magicNanos         = 0xa1b23c4d
+	magicMicrosSwapped = 0xd4c3b2a1
+	magicNanosSwapped  = 0x4d3cb2a1
+
+	blockSectionHeader     = 0x0a0d0d0a
+	blockInterface         = 0x00000001
+	blockSimplePacket      = 0x00000003
+	blockEnhancedPacket    = 0x00000006
+	byteOrderMagic         = 0x1a2b3c4d
+	optionEnd              = 0
+	optionInterfaceTSResol = 9
+
+	maxBlockSize = 16 << 20 // Anything bigger is a corrupt length
+)
+
+// Frame is one captured link layer frame
+type Frame struct {
+	Timestamp time.Time // Zero for pcapng simple packet blocks, which don't have one
+	LinkType  int
+	Data      []byte // Only valid until the next call to Next
+}
+
+// Reader reads frames from a pcap or pcapng capture
+type Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	ng    bool
+	buf   []byte
+
+	// pcap
+	linkType int
+	nanos    bool
+
+	// pcapng, per interface in the current section
+	interfaces []ngInterface
+}
+
+type ngInterface struct {
+	linkType int
+	snapLen  uint32
+	tsRate   uint64 // timestamp ticks per second
+}
+
+// IsCapture returns true if the first bytes of a file look like a pcap or pcapng capture
+func IsCapture(header []byte) bool {
+	if len(header) < 4 {
+		return false
+	}
+	switch binary.LittleEndian.Uint32(header) {
+	case magicMicros, magicNanos, magicMicrosSwapped, magicNanosSwapped, blockSectionHeader:
+		return true
+	}
+	return false
+}
+
+// NewReader reads the capture header and works out which format it is
+func NewReader(r io.Reader) (*Reader, error) {
+	pr := &Reader{r: bufio.NewReaderSize(r, 64<<10)}
+
+	magic, err := pr.r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("reading capture header: %w", err)
+	}
+
+	switch binary.LittleEndian.Uint32(magic) {
+	case blockSectionHeader:
+		pr.ng = true
+		if _, err := pr.readSectionHeader(); err != nil {
+			return nil, err
+		}
+		return pr, nil
+	case magicMicros:
+		pr.order = binary.LittleEndian
+	case magicNanos:
+		pr.order, pr.nanos = binary.LittleEndian, true
+	case magicMicrosSwapped:
+		pr.order = binary.BigEndian
+	case magicNanosSwapped:
+		pr.order, pr.nanos = binary.BigEndian, true
+	default:
+		return nil, errors.New("not a pcap or pcapng capture")
+	}
+
+	var header [24]byte
+	if _, err := io.ReadFull(pr.r, header[:]); err != nil {
+		return nil, fmt.Errorf("reading pcap header: %w", err)
+	}
+	pr.linkType = int(pr.order.Uint32(header[20:]) & 0x0fffffff) // Top bits are FCS info
+	return pr, nil
+}
+
+// Next returns the next frame, io.EOF at the end of the capture
+func (pr *Reader) Next() (Frame, error) {
+	if pr.ng {
+		return pr.nextBlock()
+	}
+	return pr.nextRecord()
+}
+
+func (pr *Reader) nextRecord() (Frame, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(pr.r, header[:]); err != nil {
+		return Frame{}, eof(err)
+	}
+
+	sec := int64(pr.order.Uint32(header[0:]))
+	frac := int64(pr.order.Uint32(header[4:]))
+	length := pr.order.Uint32(header[8:])
+	if length > maxBlockSize {
+		return Frame{}, fmt.Errorf("corrupt pcap record length %d", length)
+	}
+
+	if !pr.nanos {
+		frac *= 1000
+	}
+
+	data, err := pr.read(int(length))
+	if err != nil {
+		return Frame{}, eof(err)
+	}
+	return Frame{Timestamp: time.Unix(sec, frac), LinkType: pr.linkType, Data: data}, nil
+}
+
+func (pr *Reader) nextBlock() (Frame, error) {
+	for {
+		header, err := pr.r.Peek(8)
+		if err != nil {
+			return Frame{}, eof(err)
+		}
+
+		if binary.LittleEndian.Uint32(header) == blockSectionHeader {
+			if _, err := pr.readSectionHeader(); err != nil {
+				return Frame{}, err
+			}
+			continue
+		}
+
+		blockType := pr.order.Uint32(header[0:])
+		body, err := pr.readBlock()
+		if err != nil {
+			return Frame{}, err
+		}
+
+		switch blockType {
+		case blockInterface:
+			if len(body) < 8 {
+				return Frame{}, errors.New("short pcapng interface block")
+			}
+			iface := ngInterface{
+				linkType: int(pr.order.Uint16(body[0:])),
+				snapLen:  pr.order.Uint32(body[4:]),
+				tsRate:   1e6,
+			}
+			pr.readInterfaceOptions(body[8:], &iface)
+			pr.interfaces = append(pr.interfaces, iface)
+
+		case blockEnhancedPacket:
+			if len(body) < 20 {
+				return Frame{}, errors.New("short pcapng packet block")
+			}
+			id := pr.order.Uint32(body[0:])
+			if int(id) >= len(pr.interfaces) {
+				return Frame{}, fmt.Errorf("pcapng packet for unknown interface %d", id)
+			}
+			iface := pr.interfaces[id]
+			ticks := uint64(pr.order.Uint32(body[4:]))<<32 | uint64(pr.order.Uint32(body[8:]))
+			captured := pr.order.Uint32(body[12:])
+			if int(captured) > len(body)-20 {
+				return Frame{}, errors.New("pcapng packet longer than its block")
+			}
+			return Frame{
+				Timestamp: ticksToTime(ticks, iface.tsRate),
+				LinkType:  iface.linkType,
+				Data:      body[20 : 20+captured],
+			}, nil
+
+		case blockSimplePacket:
+			if len(body) < 4 || len(pr.interfaces) == 0 {
+				return Frame{}, errors.New("bad pcapng simple packet block")
+			}
+			iface := pr.interfaces[0]
+			captured := min(pr.order.Uint32(body[0:]), uint32(len(body)-4))
+			if iface.snapLen > 0 {
+				captured = min(captured, iface.snapLen)
+			}
+			return Frame{LinkType: iface.linkType, Data: body[4 : 4+captured]}, nil
+		}
+		// Anything else (name resolution, stats...) is skipped
+	}
+}
+
+// readSectionHeader reads a section header block, which sets the byte order and resets the interfaces
+func (pr *Reader) readSectionHeader() ([]byte, error) {
+	header, err := pr.r.Peek(12)
+	if err != nil {
+		return nil, fmt.Errorf("reading pcapng section header: %w", eof(err))
+	}
+	switch {
+	case binary.LittleEndian.Uint32(header[8:]) == byteOrderMagic:
+		pr.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header[8:]) == byteOrderMagic:
+		pr.order = binary.BigEndian
+	default:
+		return nil, errors.New("bad pcapng byte order magic")
+	}
+	pr.interfaces = pr.interfaces[:0]
+	return pr.readBlock()
+}
+
+// readBlock reads a whole pcapng block and returns its body
+func (pr *Reader) readBlock() ([]byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(pr.r, header[:]); err != nil {
+		return nil, eof(err)
+	}
+	length := pr.order.Uint32(header[4:])
+	if length < 12 || length%4 != 0 || length > maxBlockSize {
+		return nil, fmt.Errorf("corrupt pcapng block length %d", length)
+	}
+
+	block, err := pr.read(int(length) - 8)
+	if err != nil {
+		return nil, eof(err)
+	}
+	if pr.order.Uint32(block[len(block)-4:]) != length {
+		return nil, errors.New("pcapng block lengths don't match")
+	}
+	return block[:len(block)-4], nil
+}
+
+func (pr *Reader) readInterfaceOptions(options []byte, iface *ngInterface) {
+	for len(options) >= 4 {
+		code := pr.order.Uint16(options[0:])
+		length := int(pr.order.Uint16(options[2:]))
+		if code == optionEnd || 4+length > len(options) {
+			return
+		}
+		if code == optionInterfaceTSResol && length >= 1 {
+			// A power of 10 or of 2, anything past what fits in a uint64 is left at the default
+			v := options[4]
+			switch {
+			case v&0x80 == 0 && v <= 19:
+				iface.tsRate = 1
+				for range v {
+					iface.tsRate *= 10
+				}
+			case v&0x80 != 0 && v&0x7f <= 63:
+				iface.tsRate = 1 << (v & 0x7f)
+			}
+		}
+		options = options[4+(length+3)&^3:]
+	}
+}
+
+// ticksToTime works in integers so a millisecond or 2^-n resolution comes out exact
+func ticksToTime(ticks, rate uint64) time.Time {
+	hi, lo := bits.Mul64(ticks%rate, 1e9)
+	nanos, _ := bits.Div64(hi, lo, rate) // Can't overflow, ticks%rate < rate
+	return time.Unix(int64(ticks/rate), int64(nanos))
+}
+
+// read reads n bytes into the reused buffer
+func (pr *Reader) read(n int) ([]byte, error) {
+	if cap(pr.buf) < n {
+		pr.buf = make([]byte, n)
+	}
+	buf := pr.buf[:n]
+	_, err := io.ReadFull(pr.r, buf)
+	return buf, err
+}
+
+// eof turns a capture cut off part way through a record into a normal end of file,
+// that's what you get when a capture is stopped mid write
+func eof(err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return io.EOF
+	}
+	return err
+}
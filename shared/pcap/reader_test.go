@@ -0,0 +1,265 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// Fixtures are built here instead of being checked in, each is only a few frames
+
+var (
+	game    = netip.MustParseAddrPort("192.168.1.20:50000")
+	console = netip.MustParseAddrPort("192.168.1.10:9999")
+	start   = time.Unix(1700000000, 123456000)
+)
+
+type testFrame struct {
+	at   time.Time
+	data []byte
+}
+
+// pcapFile builds a classic pcap capture
+func pcapFile(order binary.AppendByteOrder, nanos bool, linkType int, frames []testFrame) []byte {
+	magic := uint32(magicMicros)
+	if nanos {
+		magic = magicNanos
+	}
+	b := order.AppendUint32(nil, magic)
+	b = order.AppendUint16(b, 2)
+	b = order.AppendUint16(b, 4)
+	b = order.AppendUint32(b, 0)     // Time zone
+	b = order.AppendUint32(b, 0)     // Accuracy
+	b = order.AppendUint32(b, 65535) // Snap length
+	b = order.AppendUint32(b, uint32(linkType))
+	for _, f := range frames {
+		frac := f.at.Nanosecond() / 1000
+		if nanos {
+			frac = f.at.Nanosecond()
+		}
+		b = order.AppendUint32(b, uint32(f.at.Unix()))
+		b = order.AppendUint32(b, uint32(frac))
+		b = order.AppendUint32(b, uint32(len(f.data)))
+		b = order.AppendUint32(b, uint32(len(f.data)))
+		b = append(b, f.data...)
+	}
+	return b
+}
+
+// ngBlock builds a pcapng block, padding the body to 4 bytes
+func ngBlock(order binary.AppendByteOrder, blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	length := uint32(12 + len(body))
+	b := order.AppendUint32(nil, blockType)
+	b = order.AppendUint32(b, length)
+	b = append(b, body...)
+	return order.AppendUint32(b, length)
+}
+
+func ngSectionHeader(order binary.AppendByteOrder) []byte {
+	body := order.AppendUint32(nil, byteOrderMagic)
+	body = order.AppendUint16(body, 1)
+	body = order.AppendUint16(body, 0)
+	body = order.AppendUint64(body, ^uint64(0)) // Section length not given
+	return ngBlock(order, blockSectionHeader, body)
+}
+
+// ngInterface builds an interface block, tsresol 0 leaves out the option (microseconds)
+func ngInterfaceBlock(order binary.AppendByteOrder, linkType int, tsresol byte) []byte {
+	body := order.AppendUint16(nil, uint16(linkType))
+	body = order.AppendUint16(body, 0)
+	body = order.AppendUint32(body, 0) // No snap length
+	if tsresol != 0 {
+		body = order.AppendUint16(body, optionInterfaceTSResol)
+		body = order.AppendUint16(body, 1)
+		body = append(body, tsresol, 0, 0, 0)
+		body = order.AppendUint32(body, optionEnd)
+	}
+	return ngBlock(order, blockInterface, body)
+}
+
+func ngPacket(order binary.AppendByteOrder, iface uint32, ticks uint64, data []byte) []byte {
+	body := order.AppendUint32(nil, iface)
+	body = order.AppendUint32(body, uint32(ticks>>32))
+	body = order.AppendUint32(body, uint32(ticks))
+	body = order.AppendUint32(body, uint32(len(data)))
+	body = order.AppendUint32(body, uint32(len(data)))
+	body = append(body, data...)
+	return ngBlock(order, blockEnhancedPacket, body)
+}
+
+func ngSimplePacket(order binary.AppendByteOrder, data []byte) []byte {
+	body := order.AppendUint32(nil, uint32(len(data)))
+	return ngBlock(order, blockSimplePacket, append(body, data...))
+}
+
+// pcapngFile builds a pcapng capture with one Ethernet interface timed in nanoseconds
+func pcapngFile(order binary.AppendByteOrder, frames []testFrame) []byte {
+	b := ngSectionHeader(order)
+	b = append(b, ngInterfaceBlock(order, LinkTypeEthernet, 9)...)
+	b = append(b, ngBlock(order, 0x00000004, []byte("name resolution, skipped"))...)
+	for _, f := range frames {
+		b = append(b, ngPacket(order, 0, uint64(f.at.UnixNano()), f.data)...)
+	}
+	return b
+}
+
+// testFrames is a Forza packet, something that isn't UDP, then another Forza packet
+func testFrames() []testFrame {
+	return []testFrame{
+		{start, ethernet(etherTypeIPv4, ipv4(protocolUDP, game.Addr(), console.Addr(), 0, udp(game, console, payload(324, 1))))},
+		{start.Add(time.Millisecond), ethernet(etherTypeIPv4, ipv4(6, game.Addr(), console.Addr(), 0, make([]byte, 40)))},
+		{start.Add(17 * time.Millisecond), ethernet(etherTypeIPv4, ipv4(protocolUDP, game.Addr(), console.Addr(), 0, udp(game, console, payload(324, 2))))},
+	}
+}
+
+func payload(size int, fill byte) []byte {
+	return bytes.Repeat([]byte{fill}, size)
+}
+
+// readAll reads every frame, returning the UDP payloads' first bytes and the timestamps
+func readAll(t *testing.T, capture []byte) (fills []byte, times []time.Time, frames int) {
+	t.Helper()
+	r, err := NewReader(bytes.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			return fills, times, frames
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		frames++
+		if d, ok := UDP(f); ok {
+			if d.Src != game || d.Dst != console || len(d.Payload) != 324 {
+				t.Fatalf("got %s -> %s with %d bytes", d.Src, d.Dst, len(d.Payload))
+			}
+			fills = append(fills, d.Payload[0])
+			times = append(times, f.Timestamp)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		capture []byte
+		unit    time.Duration // Timestamp precision
+	}{
+		{"pcap little endian", pcapFile(binary.LittleEndian, false, LinkTypeEthernet, testFrames()), time.Microsecond},
+		{"pcap big endian", pcapFile(binary.BigEndian, false, LinkTypeEthernet, testFrames()), time.Microsecond},
+		{"pcap nanoseconds", pcapFile(binary.LittleEndian, true, LinkTypeEthernet, testFrames()), time.Nanosecond},
+		{"pcap nanoseconds big endian", pcapFile(binary.BigEndian, true, LinkTypeEthernet, testFrames()), time.Nanosecond},
+		{"pcapng little endian", pcapngFile(binary.LittleEndian, testFrames()), time.Nanosecond},
+		{"pcapng big endian", pcapngFile(binary.BigEndian, testFrames()), time.Nanosecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsCapture(tt.capture) {
+				t.Fatal("IsCapture is false")
+			}
+			fills, times, frames := readAll(t, tt.capture)
+			if frames != 3 || !bytes.Equal(fills, []byte{1, 2}) {
+				t.Fatalf("got %d frames with payloads %v, want 3 frames with payloads [1 2]", frames, fills)
+			}
+			want := []time.Time{start.Truncate(tt.unit), start.Add(17 * time.Millisecond).Truncate(tt.unit)}
+			for i := range want {
+				if !times[i].Equal(want[i]) {
+					t.Errorf("frame %d at %s, want %s", i, times[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+// A capture stopped mid write ends cleanly after the last whole frame
+func TestTruncatedCapture(t *testing.T) {
+	tests := []struct {
+		name    string
+		capture []byte
+	}{
+		{"pcap", pcapFile(binary.LittleEndian, false, LinkTypeEthernet, testFrames())},
+		{"pcap big endian", pcapFile(binary.BigEndian, false, LinkTypeEthernet, testFrames())},
+		{"pcapng", pcapngFile(binary.LittleEndian, testFrames())},
+		{"pcapng big endian", pcapngFile(binary.BigEndian, testFrames())},
+	}
+	for _, tt := range tests {
+		for _, cut := range []int{1, 10, 100, 300} {
+			fills, _, frames := readAll(t, tt.capture[:len(tt.capture)-cut])
+			if frames != 2 || !bytes.Equal(fills, []byte{1}) {
+				t.Errorf("%s cut %d bytes short: got %d frames with payloads %v, want the first 2", tt.name, cut, frames, fills)
+			}
+		}
+	}
+}
+
+func TestPcapngBlocks(t *testing.T) {
+	order := binary.LittleEndian
+	packet := ethernet(etherTypeIPv4, ipv4(protocolUDP, game.Addr(), console.Addr(), 0, udp(game, console, payload(324, 7))))
+
+	// Default microsecond timestamps, a simple packet block, and a second section
+	// that's big endian with its own interfaces
+	b := ngSectionHeader(order)
+	b = append(b, ngInterfaceBlock(order, LinkTypeEthernet, 0)...)
+	b = append(b, ngPacket(order, 0, uint64(start.UnixMicro()), packet)...)
+	b = append(b, ngSimplePacket(order, packet)...)
+	b = append(b, ngSectionHeader(binary.BigEndian)...)
+	b = append(b, ngInterfaceBlock(binary.BigEndian, LinkTypeRaw, 3)...)
+	b = append(b, ngPacket(binary.BigEndian, 0, uint64(start.UnixMilli()), packet[14:])...)
+
+	r, err := NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTimes := []time.Time{start, {}, start.Truncate(time.Millisecond)}
+	wantLinks := []int{LinkTypeEthernet, LinkTypeEthernet, LinkTypeRaw}
+	for i := range wantTimes {
+		f, err := r.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !f.Timestamp.Equal(wantTimes[i]) || f.LinkType != wantLinks[i] {
+			t.Errorf("frame %d: at %s link %d, want %s link %d", i, f.Timestamp, f.LinkType, wantTimes[i], wantLinks[i])
+		}
+		if d, ok := UDP(f); !ok || d.Payload[0] != 7 {
+			t.Errorf("frame %d: no payload", i)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("got %v at the end, want io.EOF", err)
+	}
+}
+
+func TestNotACapture(t *testing.T) {
+	if IsCapture([]byte("FZRC")) {
+		t.Error("IsCapture is true for a recording")
+	}
+	if _, err := NewReader(bytes.NewReader([]byte("not a capture at all"))); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestTicksToTime(t *testing.T) {
+	tests := []struct {
+		ticks, rate uint64
+		want        time.Time
+	}{
+		{uint64(start.UnixMicro()), 1e6, start},
+		{uint64(start.UnixNano()), 1e9, start},
+		{1700000000123, 1e3, time.Unix(1700000000, 123000000)},
+		{1700000000<<20 | 1<<19, 1 << 20, time.Unix(1700000000, 500000000)},
+		{3, 1, time.Unix(3, 0)},
+	}
+	for _, tt := range tests {
+		if got := ticksToTime(tt.ticks, tt.rate); !got.Equal(tt.want) {
+			t.Errorf("%d ticks at %d/s: got %s, want %s", tt.ticks, tt.rate, got, tt.want)
+		}
+	}
+}
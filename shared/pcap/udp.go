@@ -0,0 +1,162 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+
+	protocolUDP = 17
+
+	// IPv6 extension headers that can sit in front of UDP
+	ipv6HopByHop    = 0
+	ipv6Routing     = 43
+	ipv6DestOptions = 60
+)
+
+// Datagram is a UDP payload pulled out of a frame
+type Datagram struct {
+	Src, Dst netip.AddrPort
+	Payload  []byte
+}
+
+// UDP unwraps a frame down to its UDP payload. Returns false for anything that
+// isn't an unfragmented UDP datagram over IPv4 / IPv6.
+func UDP(frame Frame) (Datagram, bool) {
+	ip, ok := ipPacket(frame.LinkType, frame.Data)
+	if !ok || len(ip) < 1 {
+		return Datagram{}, false
+	}
+
+	switch ip[0] >> 4 {
+	case 4:
+		return udpOverIPv4(ip)
+	case 6:
+		return udpOverIPv6(ip)
+	default:
+		return Datagram{}, false
+	}
+}
+
+// ipPacket strips the link layer header
+func ipPacket(linkType int, data []byte) ([]byte, bool) {
+	switch linkType {
+	case LinkTypeNull, LinkTypeLoop:
+		// 4 byte address family, the IP version nibble tells us the rest
+		if len(data) < 4 {
+			return nil, false
+		}
+		return data[4:], true
+
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return nil, false
+		}
+		etherType := binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(data) < 4 {
+				return nil, false
+			}
+			etherType = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+		return data, etherType == etherTypeIPv4 || etherType == etherTypeIPv6
+
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+		return data, true
+
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, false
+		}
+		return data[16:], true
+
+	case LinkTypeLinuxSLL2:
+		if len(data) < 20 {
+			return nil, false
+		}
+		return data[20:], true
+
+	default:
+		return nil, false
+	}
+}
+
+func udpOverIPv4(ip []byte) (Datagram, bool) {
+	if len(ip) < 20 {
+		return Datagram{}, false
+	}
+	headerLen := int(ip[0]&0x0f) * 4
+	totalLen := int(binary.BigEndian.Uint16(ip[2:]))
+	flagsFragment := binary.BigEndian.Uint16(ip[6:])
+	if headerLen < 20 || len(ip) < headerLen || ip[9] != protocolUDP {
+		return Datagram{}, false
+	}
+	// Forza packets fit in one frame, so fragments aren't worth reassembling
+	if flagsFragment&0x3fff != 0 {
+		return Datagram{}, false
+	}
+	if totalLen >= headerLen && totalLen < len(ip) {
+		ip = ip[:totalLen] // Drop any Ethernet padding
+	}
+
+	src := netip.AddrFrom4([4]byte(ip[12:16]))
+	dst := netip.AddrFrom4([4]byte(ip[16:20]))
+	return udpDatagram(src, dst, ip[headerLen:])
+}
+
+func udpOverIPv6(ip []byte) (Datagram, bool) {
+	if len(ip) < 40 {
+		return Datagram{}, false
+	}
+	payloadLen := int(binary.BigEndian.Uint16(ip[4:]))
+	next := ip[6]
+	src := netip.AddrFrom16([16]byte(ip[8:24]))
+	dst := netip.AddrFrom16([16]byte(ip[24:40]))
+
+	rest := ip[40:]
+	if payloadLen > 0 && payloadLen < len(rest) {
+		rest = rest[:payloadLen]
+	}
+
+	for next != protocolUDP {
+		switch next {
+		case ipv6HopByHop, ipv6Routing, ipv6DestOptions:
+			if len(rest) < 8 {
+				return Datagram{}, false
+			}
+			length := (int(rest[1]) + 1) * 8
+			if len(rest) < length {
+				return Datagram{}, false
+			}
+			next, rest = rest[0], rest[length:]
+		default:
+			// Fragments included (44), see udpOverIPv4
+			return Datagram{}, false
+		}
+	}
+
+	return udpDatagram(src, dst, rest)
+}
+
+func udpDatagram(src, dst netip.Addr, udp []byte) (Datagram, bool) {
+	if len(udp) < 8 {
+		return Datagram{}, false
+	}
+	length := int(binary.BigEndian.Uint16(udp[4:]))
+	if length < 8 || length > len(udp) {
+		return Datagram{}, false // Truncated by the capture's snap length
+	}
+
+	return Datagram{
+		Src:     netip.AddrPortFrom(src, binary.BigEndian.Uint16(udp[0:])),
+		Dst:     netip.AddrPortFrom(dst, binary.BigEndian.Uint16(udp[2:])),
+		Payload: udp[8:length],
+	}, true
+}
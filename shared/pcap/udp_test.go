@@ -0,0 +1,94 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+)
+
+func ethernet(etherType uint16, payload []byte) []byte {
+	b := make([]byte, 12, 14+len(payload))
+	b = binary.BigEndian.AppendUint16(b, etherType)
+	return append(b, payload...)
+}
+
+// ipv4 builds an IPv4 header, flagsFragment is the flags and fragment offset field
+func ipv4(protocol byte, src, dst netip.Addr, flagsFragment uint16, payload []byte) []byte {
+	b := []byte{0x45, 0}
+	b = binary.BigEndian.AppendUint16(b, uint16(20+len(payload)))
+	b = append(b, 0, 0)
+	b = binary.BigEndian.AppendUint16(b, flagsFragment)
+	b = append(b, 64, protocol, 0, 0)
+	b = append(b, src.AsSlice()...)
+	b = append(b, dst.AsSlice()...)
+	return append(b, payload...)
+}
+
+func ipv6(next byte, src, dst netip.Addr, payload []byte) []byte {
+	b := []byte{0x60, 0, 0, 0}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+	b = append(b, next, 64)
+	b = append(b, src.AsSlice()...)
+	b = append(b, dst.AsSlice()...)
+	return append(b, payload...)
+}
+
+func udp(src, dst netip.AddrPort, payload []byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, src.Port())
+	b = binary.BigEndian.AppendUint16(b, dst.Port())
+	b = binary.BigEndian.AppendUint16(b, uint16(8+len(payload)))
+	b = append(b, 0, 0)
+	return append(b, payload...)
+}
+
+func TestUDP(t *testing.T) {
+	forza := payload(324, 9)
+	datagram := udp(game, console, forza)
+	packet := ipv4(protocolUDP, game.Addr(), console.Addr(), 0, datagram)
+	src6 := netip.MustParseAddrPort("[fe80::1]:50000")
+	dst6 := netip.MustParseAddrPort("[fe80::2]:9999")
+	packet6 := ipv6(protocolUDP, src6.Addr(), dst6.Addr(), udp(src6, dst6, forza))
+
+	vlan := append([]byte{0, 10}, binary.BigEndian.AppendUint16(nil, etherTypeIPv4)...)
+	hopByHop := append([]byte{protocolUDP, 0, 0, 0, 0, 0, 0, 0}, udp(src6, dst6, forza)...)
+	sll := append(make([]byte, 14), binary.BigEndian.AppendUint16(nil, etherTypeIPv4)...)
+
+	tests := []struct {
+		name     string
+		linkType int
+		data     []byte
+		ok       bool
+	}{
+		{"ethernet IPv4", LinkTypeEthernet, ethernet(etherTypeIPv4, packet), true},
+		{"ethernet padding", LinkTypeEthernet, ethernet(etherTypeIPv4, append(bytes.Clone(packet), 0, 0, 0, 0)), true},
+		{"VLAN", LinkTypeEthernet, ethernet(etherTypeVLAN, append(vlan, packet...)), true},
+		{"raw IPv4", LinkTypeRaw, packet, true},
+		{"IPv4 link type", LinkTypeIPv4, packet, true},
+		{"loopback", LinkTypeNull, append([]byte{2, 0, 0, 0}, packet...), true},
+		{"Linux cooked", LinkTypeLinuxSLL, append(sll, packet...), true},
+		{"IPv6", LinkTypeEthernet, ethernet(etherTypeIPv6, packet6), true},
+		{"IPv6 extension header", LinkTypeIPv6, ipv6(ipv6HopByHop, src6.Addr(), dst6.Addr(), hopByHop), true},
+
+		{"TCP", LinkTypeEthernet, ethernet(etherTypeIPv4, ipv4(6, game.Addr(), console.Addr(), 0, datagram)), false},
+		{"ICMP", LinkTypeRaw, ipv4(1, game.Addr(), console.Addr(), 0, datagram), false},
+		{"ARP", LinkTypeEthernet, ethernet(0x0806, packet), false},
+		{"first fragment", LinkTypeRaw, ipv4(protocolUDP, game.Addr(), console.Addr(), 0x2000, datagram), false},
+		{"later fragment", LinkTypeRaw, ipv4(protocolUDP, game.Addr(), console.Addr(), 10, datagram), false},
+		{"don't fragment", LinkTypeRaw, ipv4(protocolUDP, game.Addr(), console.Addr(), 0x4000, datagram), true},
+		{"cut by snap length", LinkTypeRaw, packet[:100], false},
+		{"IPv6 fragment", LinkTypeIPv6, ipv6(44, src6.Addr(), dst6.Addr(), hopByHop), false},
+		{"unknown link type", 147, packet, false},
+		{"short frame", LinkTypeEthernet, make([]byte, 10), false},
+	}
+	for _, tt := range tests {
+		d, ok := UDP(Frame{LinkType: tt.linkType, Data: tt.data})
+		if ok != tt.ok {
+			t.Errorf("%s: got %t, want %t", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && !bytes.Equal(d.Payload, forza) {
+			t.Errorf("%s: got a %d byte payload", tt.name, len(d.Payload))
+		}
+	}
+}
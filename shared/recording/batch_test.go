@@ -0,0 +1,55 @@
+package recording
+
+import (
+	"bytes"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"io"
+	"testing"
+	"time"
+)
+
+// A gzipped container recording can be read a batch at a time
+func TestPacketBatchReader(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Format: packethandling.FormatHorizon, PacketSize: packethandling.FormatHorizon.Size(), Flags: FlagGzip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1700000000, 0)
+	for i := range 10 {
+		p := packethandling.ForzaHorizon5Packet{TimeStampMS: uint32(i), Speed: float32(i)}
+		if err := w.WritePacket(packethandling.MarshalPacket(&p), start.Add(time.Duration(i)*time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(&buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := packethandling.NewPacketBatchReader(r)
+	dst := make([]packethandling.ForzaHorizon5Packet, 4)
+	var got []uint32
+	for {
+		n, err := br.Read(dst)
+		for _, p := range dst[:n] {
+			got = append(got, p.TimeStampMS)
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != 10 {
+		t.Fatalf("got %d packets, want 10", len(got))
+	}
+	for i, ts := range got {
+		if ts != uint32(i) {
+			t.Fatalf("packet %d has timestamp %d", i, ts)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package recording
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"time"
+)
+
+// An index sits next to its recording as <recording>.idx:
+//
+//	magic "FZIX" | version u16 | recording size i64 | recording modified unix nanos i64 | entry count u32 | entries
+//	entry  record u64 | offset i64 | elapsed ns i64 | received unix nanos i64 |
+//	       TimeStampMS u32 | CurrentRaceTime f32 | LapNumber u16 | event u8 | race on u8
+//
+// Entries are written for the first packet, once a second of recording, and
+// whenever the lap or IsRaceOn changes. Seeking goes to an entry and reads forward.
+// An index whose recording has a different size or modification time, or that's
+// another version, is out of date and gets built again.
+
+const (
+	indexMagic     = "FZIX"
+	indexVersion   = 2
+	indexHeadSize  = 4 + 2 + 8 + 8 + 4
+	indexEntrySize = 8 + 8 + 8 + 8 + 4 + 4 + 2 + 1 + 1
+
+	// IndexExt is added to a recording's path for its index
+	IndexExt = ".idx"
+
+	checkpointInterval = time.Second
+)
+
+// ErrStaleIndex is returned when an index doesn't match its recording any more
+var ErrStaleIndex = errors.New("recording: index is out of date")
+
+// Event is why an index entry was written
+type Event uint8
+
+const (
+	EventCheckpoint Event = iota // Regular entry, also the first packet
+	EventLap                     // LapNumber changed
+	EventRaceStart               // IsRaceOn went on
+	EventRaceEnd                 // IsRaceOn went off
+)
+
+func (e Event) String() string {
+	switch e {
+	case EventCheckpoint:
+		return "checkpoint"
+	case EventLap:
+		return "lap"
+	case EventRaceStart:
+		return "race start"
+	case EventRaceEnd:
+		return "race end"
+	default:
+		return fmt.Sprintf("Event(%d)", int(e))
+	}
+}
+
+// Entry is one indexed packet
+type Entry struct {
+	Record      uint64        // Packet number, from 0
+	Offset      int64         // Where the record starts, see Reader.Offset
+	Elapsed     time.Duration // Time into the recording, from a default Timeline
+	Received    time.Time     // Zero for legacy recordings
+	TimeStampMS uint32
+	RaceTime    float32 // CurrentRaceTime
+	Lap         uint16  // LapNumber
+	Event       Event
+	RaceOn      bool
+}
+
+// Index is the entries for one recording, in order
+type Index struct {
+	Entries  []Entry
+	size     int64 // Size of the recording it was built from
+	modified int64 // and its modification time in unix nanos
+}
+
+// IndexPath returns where the index for a recording lives
+func IndexPath(recordingPath string) string {
+	return recordingPath + IndexExt
+}
+
+// Indexer builds an index as packets are written or read
+type Indexer struct {
+	ix       Index
+	timeline Timeline
+	packet   packethandling.ForzaHorizon5Packet
+	record   uint64
+	last     Entry // The last packet added, indexed or not
+	lastMark time.Duration
+}
+
+// Add indexes a packet that starts at offset
+func (x *Indexer) Add(offset int64, received time.Time, data []byte) {
+	parsed := packethandling.ParsePacket(data, &x.packet) == nil
+
+	e := Entry{Record: x.record, Offset: offset, Received: received}
+	if parsed {
+		e.TimeStampMS = x.packet.TimeStampMS
+		e.RaceOn = x.packet.IsRaceOn != 0
+		if x.packet.Format.HasDash() {
+			e.RaceTime = x.packet.CurrentRaceTime
+			e.Lap = x.packet.LapNumber
+		}
+	} else {
+		// Carry the race state over a bad packet rather than make up transitions
+		e.RaceOn, e.RaceTime, e.Lap = x.last.RaceOn, x.last.RaceTime, x.last.Lap
+	}
+	e.Elapsed = x.timeline.Advance(received, e.TimeStampMS)
+
+	index := true
+	switch {
+	case x.record == 0:
+		e.Event = EventCheckpoint
+	case e.RaceOn && !x.last.RaceOn:
+		e.Event = EventRaceStart
+	case !e.RaceOn && x.last.RaceOn:
+		e.Event = EventRaceEnd
+	case e.Lap != x.last.Lap:
+		e.Event = EventLap
+	case e.Elapsed-x.lastMark >= checkpointInterval:
+		e.Event = EventCheckpoint
+	default:
+		index = false
+	}
+	if index {
+		x.ix.Entries = append(x.ix.Entries, e)
+		x.lastMark = e.Elapsed
+	}
+
+	x.last = e
+	x.record++
+}
+
+// Index returns what's been built, the Indexer can carry on being used
+func (x *Indexer) Index() *Index {
+	return &Index{Entries: slices.Clip(x.ix.Entries)}
+}
+
+// BuildIndex reads a whole recording to index it
+func BuildIndex(path string, legacySize int) (*Index, error) {
+	r, err := Open(path, legacySize)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var x Indexer
+	for {
+		offset := r.Offset()
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		var damage *DamageError
+		if errors.As(err, &damage) {
+			continue // Offsets still line up, they count what was skipped
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if rec.Kind == KindPacket {
+			x.Add(offset, rec.Received, rec.Data)
+		}
+	}
+	return x.Index(), nil
+}
+
+// OpenIndex loads a recording's index, building and saving it if it's missing
+// or out of date. If it was built but couldn't be saved it's returned with the error.
+func OpenIndex(path string, legacySize int) (*Index, error) {
+	ix, err := LoadIndex(path)
+	if err == nil {
+		return ix, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, ErrStaleIndex) {
+		return nil, err
+	}
+
+	ix, err = BuildIndex(path, legacySize)
+	if err != nil {
+		return nil, err
+	}
+	return ix, ix.Save(path)
+}
+
+// LoadIndex loads a recording's index, returning an os.ErrNotExist error if
+// there isn't one and ErrStaleIndex if the recording has changed since
+func LoadIndex(path string) (*Index, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(IndexPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	var head [indexHeadSize]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil || string(head[:4]) != indexMagic {
+		return nil, fmt.Errorf("%s: not an index", IndexPath(path))
+	}
+	if v := le.Uint16(head[4:]); v != indexVersion {
+		return nil, fmt.Errorf("%s: index version %d: %w", IndexPath(path), v, ErrStaleIndex)
+	}
+	ix := &Index{size: int64(le.Uint64(head[6:])), modified: int64(le.Uint64(head[14:]))}
+	if ix.size != info.Size() || ix.modified != info.ModTime().UnixNano() {
+		return nil, ErrStaleIndex
+	}
+
+	count := le.Uint32(head[22:])
+	ix.Entries = make([]Entry, 0, min(count, 1<<20))
+	var b [indexEntrySize]byte
+	for range count {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, fmt.Errorf("%s: %w", IndexPath(path), err)
+		}
+		e := Entry{
+			Record:      le.Uint64(b[0:]),
+			Offset:      int64(le.Uint64(b[8:])),
+			Elapsed:     time.Duration(le.Uint64(b[16:])),
+			TimeStampMS: le.Uint32(b[32:]),
+			RaceTime:    math.Float32frombits(le.Uint32(b[36:])),
+			Lap:         le.Uint16(b[40:]),
+			Event:       Event(b[42]),
+			RaceOn:      b[43] != 0,
+		}
+		if nanos := int64(le.Uint64(b[24:])); nanos != 0 {
+			e.Received = time.Unix(0, nanos)
+		}
+		ix.Entries = append(ix.Entries, e)
+	}
+	return ix, nil
+}
+
+// Save writes the index next to the recording at path
+func (ix *Index) Save(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	ix.size = info.Size()
+	ix.modified = info.ModTime().UnixNano()
+
+	// Written to a temp file first so a crash can't leave half an index
+	tmp := IndexPath(path) + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+
+	var head [indexHeadSize]byte
+	copy(head[:], indexMagic)
+	le.PutUint16(head[4:], indexVersion)
+	le.PutUint64(head[6:], uint64(ix.size))
+	le.PutUint64(head[14:], uint64(ix.modified))
+	le.PutUint32(head[22:], uint32(len(ix.Entries)))
+	w.Write(head[:])
+
+	var b [indexEntrySize]byte
+	for _, e := range ix.Entries {
+		var nanos int64
+		if !e.Received.IsZero() {
+			nanos = e.Received.UnixNano()
+		}
+		le.PutUint64(b[0:], e.Record)
+		le.PutUint64(b[8:], uint64(e.Offset))
+		le.PutUint64(b[16:], uint64(e.Elapsed))
+		le.PutUint64(b[24:], uint64(nanos))
+		le.PutUint32(b[32:], e.TimeStampMS)
+		le.PutUint32(b[36:], math.Float32bits(e.RaceTime))
+		le.PutUint16(b[40:], e.Lap)
+		b[42] = uint8(e.Event)
+		b[43] = 0
+		if e.RaceOn {
+			b[43] = 1
+		}
+		w.Write(b[:])
+	}
+
+	err = w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, IndexPath(path))
+}
+
+// AtElapsed returns the last entry at or before d into the recording
+func (ix *Index) AtElapsed(d time.Duration) (Entry, bool) {
+	i := sort.Search(len(ix.Entries), func(i int) bool { return ix.Entries[i].Elapsed > d })
+	if i == 0 {
+		return Entry{}, false
+	}
+	return ix.Entries[i-1], true
+}
+
+// Lap returns the first packet of a lap (by LapNumber) while racing
+func (ix *Index) Lap(lap uint16) (Entry, bool) {
+	for _, e := range ix.Entries {
+		if e.RaceOn && e.Lap == lap {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// AtRaceTime returns the entry to read forward from to find CurrentRaceTime t
+// seconds into the first race that gets that far
+func (ix *Index) AtRaceTime(t float32) (Entry, bool) {
+	var before Entry
+	found := false
+	for _, e := range ix.Entries {
+		if !e.RaceOn {
+			found = false
+			continue
+		}
+		if e.RaceTime >= t {
+			if found {
+				return before, true
+			}
+			return e, true
+		}
+		before, found = e, true
+	}
+	return Entry{}, false
+}
+
+// AtTimestamp returns the entry to read forward from to find the first packet with TimeStampMS ms
+func (ix *Index) AtTimestamp(ms uint32) (Entry, bool) {
+	var before Entry
+	found := false
+	for _, e := range ix.Entries {
+		if e.TimeStampMS == 0 {
+			continue
+		}
+		if int32(e.TimeStampMS-ms) >= 0 {
+			if found {
+				return before, true
+			}
+			return e, true
+		}
+		before, found = e, true
+	}
+	return Entry{}, false
+}
+
+// Events returns the entries for one kind of event, e.g. EventRaceStart for every race
+func (ix *Index) Events(event Event) []Entry {
+	var events []Entry
+	for _, e := range ix.Entries {
+		if e.Event == event {
+			events = append(events, e)
+		}
+	}
+	return events
+}
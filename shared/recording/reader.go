@@ -0,0 +1,443 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"io"
+	"os"
+	"time"
+)
+
+// maxMetadata stops a garbage header making us allocate gigabytes
+const maxMetadata = 1 << 20
+
+// readBufferSize holds the biggest record, so one can be checked before it's read
+const readBufferSize = 128 * 1024
+
+var (
+	// gzipMagic starts a gzipped legacy recording
+	gzipMagic = []byte{0x1f, 0x8b}
+
+	// memberHeader starts every gzip member the Writer writes: magic, deflate and no flags
+	memberHeader = []byte{0x1f, 0x8b, 0x08, 0x00}
+
+	syncBytes = le.AppendUint16(nil, syncMarker)
+)
+
+// DamageError is returned by Next for a stretch of the recording it skipped
+// because it couldn't be read. Calling Next again carries on after it.
+type DamageError struct {
+	Offset  int64 // Where the damage starts, like Reader.Offset
+	Skipped int64 // Bytes skipped, decompressed for compressed recordings
+	Err     error // ErrSync, ErrChecksum, io.ErrUnexpectedEOF for a cut off end, or a decompression error
+}
+
+func (e *DamageError) Error() string {
+	if e.Skipped == 0 {
+		return fmt.Sprintf("recording: cut off at offset %d: %v", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("recording: skipped %d damaged bytes at offset %d: %v", e.Skipped, e.Offset, e.Err)
+}
+
+func (e *DamageError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads a recording, either the container format or a legacy file of
+// back to back packets. Compressed recordings are decompressed as they're read.
+type Reader struct {
+	src    io.Reader // The file as given, before buffering or decompressing
+	r      *bufio.Reader
+	raw    *bufio.Reader // The compressed file, nil unless gzip
+	zr     *gzip.Reader
+	closer io.Closer
+	header Header
+	legacy bool
+	gzip   bool
+	buf    []byte
+
+	bodyStart int64 // Where the records start in src
+	offset    int64 // Offset of the next record from the start of the (decompressed) records
+	ended     bool  // The rest can't be read, e.g. the gzip stream is corrupt
+
+	damaged int   // Stretches skipped
+	skipped int64 // Bytes skipped
+
+	trailer    Trailer
+	hasTrailer bool
+}
+
+// IsRecording returns true if b starts with the recording magic
+func IsRecording(b []byte) bool {
+	return bytes.HasPrefix(b, []byte(magic))
+}
+
+// NewReader reads a recording's header from r. Legacy files without one are
+// read as back to back packets of legacySize.
+func NewReader(r io.Reader, legacySize int) (*Reader, error) {
+	return newReader(r, nil, legacySize)
+}
+
+// Open opens a recording file, Close closes it
+func Open(path string, legacySize int) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rr, err := newReader(f, f, legacySize)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rr, nil
+}
+
+func newReader(r io.Reader, closer io.Closer, legacySize int) (*Reader, error) {
+	rr := &Reader{src: r, r: bufio.NewReaderSize(r, readBufferSize), closer: closer}
+
+	start, err := rr.r.Peek(len(magic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(start, gzipMagic) {
+		// A whole legacy file run through gzip
+		rr.gzip = true
+		if err := rr.decompress(); err != nil {
+			return nil, err
+		}
+		return rr, rr.legacyHeader(legacySize)
+	}
+	if !IsRecording(start) {
+		return rr, rr.legacyHeader(legacySize)
+	}
+
+	if err := rr.readHeader(); err != nil {
+		return nil, err
+	}
+	if rr.header.Flags&FlagGzip != 0 {
+		rr.gzip = true
+		if err := rr.decompress(); err != nil {
+			return nil, err
+		}
+	}
+	return rr, nil
+}
+
+// decompress reads the rest of the file through gzip
+func (r *Reader) decompress() error {
+	r.raw = r.r
+	zr, err := gzip.NewReader(r.raw)
+	if err != nil {
+		return fmt.Errorf("recording: %w", err)
+	}
+	r.zr = zr
+	r.r = bufio.NewReaderSize(zr, readBufferSize)
+	return nil
+}
+
+// restart carries on decompressing from the next gzip member after the
+// decompressor gave up. The Writer starts one every Flush, so only what was
+// written since the last one is lost. Returns false if there isn't another.
+func (r *Reader) restart() bool {
+	for {
+		b, err := r.raw.Peek(readBufferSize)
+		i := bytes.Index(b, memberHeader)
+		if i < 0 {
+			if err != nil {
+				return false
+			}
+			r.raw.Discard(len(b) - len(memberHeader) + 1) // Keep what could be the start of a header
+			continue
+		}
+		r.raw.Discard(i)
+		if r.zr.Reset(r.raw) == nil {
+			r.r.Reset(r.zr)
+			return true
+		}
+		// Not a member after all, look again after what Reset read
+	}
+}
+
+func (r *Reader) legacyHeader(size int) error {
+	format := packethandling.DetectFormat(size)
+	if format == packethandling.FormatUnknown {
+		return fmt.Errorf("unsupported packet size: %d", size)
+	}
+	r.legacy = true
+	r.header = Header{Format: format, PacketSize: size}
+	r.buf = make([]byte, size)
+	return nil
+}
+
+func (r *Reader) readHeader() error {
+	var h [headerSize]byte
+	if _, err := io.ReadFull(r.r, h[:]); err != nil {
+		return fmt.Errorf("recording: reading header: %w", err)
+	}
+
+	r.header = Header{
+		Version:    le.Uint16(h[4:]),
+		Format:     decodeFormat(h[6]),
+		PacketSize: int(le.Uint16(h[7:])),
+		Flags:      Flags(h[9]),
+	}
+	if r.header.Version == 0 || r.header.Version > Version {
+		return fmt.Errorf("recording: unsupported version %d", r.header.Version)
+	}
+
+	metaLen := le.Uint32(h[10:])
+	if metaLen > maxMetadata {
+		return fmt.Errorf("recording: %d bytes of metadata, file is probably corrupt", metaLen)
+	}
+	meta := make([]byte, metaLen)
+	if _, err := io.ReadFull(r.r, meta); err != nil {
+		return fmt.Errorf("recording: reading metadata: %w", err)
+	}
+	if len(meta) > 0 {
+		if err := json.Unmarshal(meta, &r.header.Metadata); err != nil {
+			return fmt.Errorf("recording: metadata: %w", err)
+		}
+	}
+
+	r.bodyStart = headerSize + int64(metaLen)
+	r.buf = make([]byte, MaxPayload)
+	return nil
+}
+
+// Header returns the header, legacy recordings only have the format and size filled in
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Legacy returns true if this is a bare packet file without a header
+func (r *Reader) Legacy() bool {
+	return r.legacy
+}
+
+// Offset is where the next record starts, counted from the first record.
+// For compressed recordings it's counted in decompressed bytes, so after damage
+// that lost some of them it no longer lines up with the Writer's.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+// SeekOffset moves to a record boundary given by Offset, e.g. from an index.
+// Going backwards needs a seekable file, and compressed recordings have to
+// decompress their way forward from the start.
+func (r *Reader) SeekOffset(offset int64) error {
+	seeker, canSeek := r.src.(io.Seeker)
+
+	switch {
+	case offset == r.offset:
+		return nil
+
+	case canSeek && !r.gzip:
+		if _, err := seeker.Seek(r.bodyStart+offset, io.SeekStart); err != nil {
+			return err
+		}
+		r.r.Reset(r.src)
+		r.offset = offset
+		r.ended = false
+		return nil
+
+	case offset < r.offset:
+		if !canSeek {
+			return errors.New("recording: can't seek backwards in a stream")
+		}
+		if _, err := seeker.Seek(r.bodyStart, io.SeekStart); err != nil {
+			return err
+		}
+		r.r = bufio.NewReaderSize(r.src, readBufferSize)
+		if err := r.decompress(); err != nil {
+			return err
+		}
+		r.offset = 0
+		r.ended = false
+	}
+
+	n, err := r.r.Discard(int(offset - r.offset))
+	r.offset += int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Trailer returns the trailer once it's been read, i.e. at the end. A recording
+// without one wasn't closed properly, e.g. the recorder crashed.
+func (r *Reader) Trailer() (Trailer, bool) {
+	return r.trailer, r.hasTrailer
+}
+
+// Skipped returns how many damaged stretches Next has skipped and their total size
+func (r *Reader) Skipped() (stretches int, bytes int64) {
+	return r.damaged, r.skipped
+}
+
+// Next returns the next record, its Data is only valid until the next call.
+// Returns io.EOF at the end. Anything unreadable is skipped and returned as a
+// *DamageError, e.g. garbage between records or a file cut off part way through
+// one, then the next call returns the first good record after it.
+func (r *Reader) Next() (Record, error) {
+	if r.ended {
+		return Record{}, io.EOF
+	}
+	if r.legacy {
+		return r.nextLegacy()
+	}
+
+	h, data, err := r.peekRecord()
+	switch {
+	case err == io.EOF:
+		return Record{}, io.EOF
+	case isDamage(err):
+		return Record{}, r.resync(err)
+	case err != nil:
+		return Record{}, err
+	}
+
+	rec := Record{Kind: Kind(h[2]), Data: r.buf[:len(data)]}
+	copy(rec.Data, data)
+	if nanos := int64(le.Uint64(h[5:])); nanos != 0 {
+		rec.Received = time.Unix(0, nanos)
+	}
+	n := len(h) + len(data)
+	r.r.Discard(n)
+	r.offset += int64(n)
+
+	if rec.Kind == KindTrailer {
+		r.hasTrailer = json.Unmarshal(rec.Data, &r.trailer) == nil
+	}
+	return rec, nil
+}
+
+func (r *Reader) nextLegacy() (Record, error) {
+	n, err := io.ReadFull(r.r, r.buf)
+	if err == nil {
+		r.offset += int64(n)
+		return Record{Kind: KindPacket, Data: r.buf}, nil
+	}
+	if err == io.EOF || !isDamage(err) {
+		return Record{}, err
+	}
+	// Packets have no markers to find again, so whatever's left is lost
+	r.ended = true
+	return Record{}, r.damage(r.offset, int64(n), err)
+}
+
+// peekRecord checks the record at the read position without reading it
+func (r *Reader) peekRecord() (head, data []byte, err error) {
+	h, err := r.r.Peek(recordHeaderSize)
+	if err != nil {
+		if len(h) > 0 && err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, err
+	}
+	if le.Uint16(h) != syncMarker {
+		return nil, nil, ErrSync
+	}
+
+	size := recordHeaderSize + int(le.Uint16(h[3:]))
+	b, err := r.r.Peek(size)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, err
+	}
+	head, data = b[:recordHeaderSize], b[recordHeaderSize:]
+	if recordChecksum(head, data) != le.Uint32(head[13:]) {
+		return nil, nil, ErrChecksum
+	}
+	return head, data, nil
+}
+
+// resync skips forward to the next record that checks out, a sync marker
+// followed by a header and payload that match their CRC, or to the end
+func (r *Reader) resync(reason error) error {
+	start := r.offset
+	skip := func(n int) {
+		n, _ = r.r.Discard(n)
+		r.offset += int64(n)
+	}
+
+	skip(1)
+	for {
+		b, err := r.r.Peek(readBufferSize)
+		i := bytes.Index(b, syncBytes)
+		if i < 0 {
+			if err != nil {
+				// Nothing more to find, or the decompressor gave up
+				skip(len(b))
+				if err != io.EOF && r.gzip && !r.legacy && r.restart() {
+					continue
+				}
+				r.ended = err != io.EOF
+				if r.ended && reason == io.ErrUnexpectedEOF {
+					reason = err
+				}
+				return r.damage(start, r.offset-start, reason)
+			}
+			skip(len(b) - 1) // Keep the last byte, it could be half a marker
+			continue
+		}
+		skip(i)
+
+		_, _, err = r.peekRecord()
+		switch {
+		case err == nil:
+			return r.damage(start, r.offset-start, reason)
+		case !isDamage(err):
+			return err
+		}
+		skip(1)
+	}
+}
+
+func (r *Reader) damage(offset, n int64, reason error) error {
+	if n > 0 {
+		r.damaged++ // Being cut off between records loses nothing
+		r.skipped += n
+	}
+	return &DamageError{Offset: offset, Skipped: n, Err: reason}
+}
+
+// isDamage returns true for errors from a bad recording rather than failing to read it
+func isDamage(err error) bool {
+	var corrupt flate.CorruptInputError
+	return errors.Is(err, ErrSync) || errors.Is(err, ErrChecksum) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, gzip.ErrChecksum) || errors.Is(err, gzip.ErrHeader) || errors.As(err, &corrupt)
+}
+
+// NextPacket skips to the next packet record
+func (r *Reader) NextPacket() (Record, error) {
+	for {
+		rec, err := r.Next()
+		if err != nil || rec.Kind == KindPacket {
+			return rec, err
+		}
+	}
+}
+
+// ReadPacket returns the next packet's data, so the Reader can be used with
+// packethandling.NewPacketBatchReader. It's only valid until the next call.
+func (r *Reader) ReadPacket() ([]byte, error) {
+	rec, err := r.NextPacket()
+	return rec.Data, err
+}
+
+// Close closes the file if the Reader opened it
+func (r *Reader) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Package recording reads and writes recorded telemetry sessions.
+//
+// A recording is a header followed by records, all little endian like the game's packets:
+//
+//	header  magic "FZRC" | version u16 | format u8 | packet size u16 | flags u8 | metadata length u32 | metadata JSON
+//	record  sync "FZ" u16 | kind u8 | length u16 | received unix nanos i64 | crc32 u32 | payload
+//
+// The CRC covers the kind, length, time and payload, a reader that hits damage
+// skips to the next sync marker whose record passes its CRC. Close writes a
+// trailer record, a recording without one was cut off while it was being
+// written. With FlagGzip everything after the header is gzipped, a new gzip
+// member every Flush so a reader can pick up again at the next one after
+// damage. Files from before the container existed are bare back to back
+// packets and can still be read, gzipped or not.
+package recording
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/sequencing"
+	"hash/crc32"
+	"time"
+)
+
+// Version is the newest container version this package writes and reads
+const Version = 1
+
+const (
+	magic = "FZRC"
+
+	// syncMarker starts every record, "FZ" on disk
+	syncMarker = 0x5A46
+
+	headerSize       = 4 + 2 + 1 + 2 + 1 + 4
+	recordHeaderSize = 2 + 1 + 2 + 8 + 4
+
+	// MaxPayload is the largest record a recording can hold
+	MaxPayload = 0xFFFF
+)
+
+var (
+	ErrNotRecording = errors.New("recording: not a recording")
+	ErrChecksum     = errors.New("recording: record checksum mismatch")
+	ErrSync         = errors.New("recording: lost record sync")
+)
+
+// Flags are the header's feature bits
+type Flags uint8
+
+const (
+	FlagGzip Flags = 1 << iota // Records are gzip compressed
+)
+
+// Format codes in the header. They're fixed here rather than taken from
+// packethandling.Format so adding or reordering formats there can't change what
+// an old file says it holds.
+const (
+	formatCodeUnknown        uint8 = 0
+	formatCodeSled           uint8 = 1
+	formatCodeDash           uint8 = 2
+	formatCodeHorizon        uint8 = 3
+	formatCodeMotorsport2023 uint8 = 4
+)
+
+var formatCodes = []struct {
+	code   uint8
+	format packethandling.Format
+}{
+	{formatCodeSled, packethandling.FormatMotorsport7Sled},
+	{formatCodeDash, packethandling.FormatMotorsport7Dash},
+	{formatCodeHorizon, packethandling.FormatHorizon},
+	{formatCodeMotorsport2023, packethandling.FormatMotorsport2023},
+}
+
+// encodeFormat returns the header code for a format
+func encodeFormat(f packethandling.Format) uint8 {
+	for _, c := range formatCodes {
+		if c.format == f {
+			return c.code
+		}
+	}
+	return formatCodeUnknown
+}
+
+// decodeFormat returns the format for a header code, FormatUnknown for one from a newer version
+func decodeFormat(code uint8) packethandling.Format {
+	for _, c := range formatCodes {
+		if c.code == code {
+			return c.format
+		}
+	}
+	return packethandling.FormatUnknown
+}
+
+// Kind is what a record holds
+type Kind uint8
+
+const (
+	KindPacket  Kind = 1 // A datagram exactly as received
+	KindTrailer Kind = 2 // Trailer JSON, the last record of a recording that was closed properly
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindPacket:
+		return "packet"
+	case KindTrailer:
+		return "trailer"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// Metadata describes the session, stored as JSON in the header
+type Metadata struct {
+	Start      time.Time `json:"start"`
+	CarOrdinal int32     `json:"car_ordinal,omitempty"`
+	Source     string    `json:"source,omitempty"`
+	Notes      string    `json:"notes,omitempty"`
+}
+
+// Trailer is written as JSON when a recording is closed
+type Trailer struct {
+	Records int               `json:"records"`          // Records before the trailer
+	End     *time.Time        `json:"end,omitempty"`    // Receive time of the last one, if any had one
+	Health  *sequencing.Stats `json:"health,omitempty"` // Stream health while recording, if the recorder tracked it
+}
+
+// Header is everything before the first record
+type Header struct {
+	Version    uint16
+	Format     packethandling.Format // Format of the first packet, the rest can differ
+	PacketSize int
+	Flags      Flags
+	Metadata   Metadata
+}
+
+// Record is one entry in a recording
+type Record struct {
+	Kind     Kind
+	Received time.Time // When it was received, zero for legacy recordings
+	Data     []byte
+}
+
+var le = binary.LittleEndian
+
+// recordChecksum covers the record header after the sync marker up to the checksum, then the payload
+func recordChecksum(head []byte, payload []byte) uint32 {
+	crc := crc32.ChecksumIEEE(head[2:13])
+	return crc32.Update(crc, crc32.IEEETable, payload)
+}
@@ -0,0 +1,486 @@
+package recording
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+var start = time.Unix(1700000000, 0)
+
+// session returns n Horizon packets 16ms apart: menu, then racing from packet
+// 10 with lap 1 from packet 30, then back to the menu from packet 50
+func session(n int) [][]byte {
+	var packets [][]byte
+	for i := range n {
+		p := packethandling.ForzaHorizon5Packet{TimeStampMS: uint32(1000 + 16*i)}
+		if i >= 10 && i < 50 {
+			p.IsRaceOn = 1
+		}
+		if i >= 30 {
+			p.LapNumber = 1
+		}
+		packets = append(packets, packethandling.MarshalPacket(&p))
+	}
+	return packets
+}
+
+func received(i int) time.Time {
+	return start.Add(time.Duration(i) * 16 * time.Millisecond)
+}
+
+// write writes packets to a recording, indexing them if ix isn't nil
+func write(t *testing.T, w io.Writer, flags Flags, packets [][]byte, ix *Indexer) {
+	t.Helper()
+	rw, err := NewWriter(w, Header{
+		Format:     packethandling.FormatHorizon,
+		PacketSize: packethandling.FormatHorizon.Size(),
+		Flags:      flags,
+		Metadata:   Metadata{Start: start, CarOrdinal: 1234, Source: "test", Notes: "wet"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range packets {
+		if ix != nil {
+			ix.Add(rw.Offset(), received(i), p)
+		}
+		if err := rw.WritePacket(p, received(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readAll reads every packet, returning the damage errors it was given along the way
+func readAll(t *testing.T, r *Reader) (packets [][]byte, times []time.Time, damage []*DamageError) {
+	t.Helper()
+	for {
+		rec, err := r.NextPacket()
+		var d *DamageError
+		switch {
+		case err == io.EOF:
+			return packets, times, damage
+		case errors.As(err, &d):
+			damage = append(damage, d)
+			continue
+		case err != nil:
+			t.Fatal(err)
+		}
+		packets = append(packets, bytes.Clone(rec.Data))
+		times = append(times, rec.Received)
+	}
+}
+
+func TestContainerRoundTrip(t *testing.T) {
+	for _, flags := range []Flags{0, FlagGzip} {
+		var buf bytes.Buffer
+		packets := session(60)
+		write(t, &buf, flags, packets, nil)
+
+		r, err := NewReader(&buf, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := r.Header()
+		want := Header{
+			Version:    Version,
+			Format:     packethandling.FormatHorizon,
+			PacketSize: packethandling.FormatHorizon.Size(),
+			Flags:      flags,
+			Metadata:   Metadata{Start: start, CarOrdinal: 1234, Source: "test", Notes: "wet"},
+		}
+		if !h.Metadata.Start.Equal(start) {
+			t.Errorf("flags %d: start %s", flags, h.Metadata.Start)
+		}
+		h.Metadata.Start = start
+		if !reflect.DeepEqual(h, want) || r.Legacy() {
+			t.Errorf("flags %d: got header %+v, want %+v", flags, h, want)
+		}
+
+		got, times, damage := readAll(t, r)
+		if len(damage) > 0 || len(got) != len(packets) {
+			t.Fatalf("flags %d: got %d packets and %v", flags, len(got), damage)
+		}
+		for i := range packets {
+			if !bytes.Equal(got[i], packets[i]) || !times[i].Equal(received(i)) {
+				t.Fatalf("flags %d: packet %d differs", flags, i)
+			}
+		}
+		trailer, ok := r.Trailer()
+		if !ok || trailer.Records != len(packets) || trailer.End == nil || !trailer.End.Equal(received(len(packets)-1)) {
+			t.Errorf("flags %d: got trailer %+v, %t", flags, trailer, ok)
+		}
+	}
+}
+
+// The header and record layout are checked byte by byte, they're what old files hold
+func TestLayout(t *testing.T) {
+	var buf bytes.Buffer
+	packet := session(1)[0]
+	write(t, &buf, 0, [][]byte{packet}, nil)
+	b := buf.Bytes()
+
+	metaLen := int(le.Uint32(b[10:]))
+	if string(b[:4]) != "FZRC" || le.Uint16(b[4:]) != 1 || b[6] != 3 || le.Uint16(b[7:]) != 324 || b[9] != 0 {
+		t.Fatalf("header % x", b[:14])
+	}
+
+	rec := b[14+metaLen:]
+	if string(rec[:2]) != "FZ" || rec[2] != 1 || le.Uint16(rec[3:]) != 324 || int64(le.Uint64(rec[5:])) != start.UnixNano() {
+		t.Fatalf("record header % x", rec[:17])
+	}
+	crc := crc32.Update(crc32.ChecksumIEEE(rec[2:13]), crc32.IEEETable, rec[17:17+324])
+	if le.Uint32(rec[13:]) != crc {
+		t.Fatalf("record crc %#x, want %#x", le.Uint32(rec[13:]), crc)
+	}
+	if !bytes.Equal(rec[17:17+324], packet) {
+		t.Fatal("payload differs")
+	}
+	if trailer := rec[17+324:]; string(trailer[:2]) != "FZ" || trailer[2] != 2 {
+		t.Fatalf("no trailer record after the packet: % x", trailer[:3])
+	}
+}
+
+// Format codes on disk never change, whatever happens to packethandling.Format
+func TestFormatCodes(t *testing.T) {
+	want := map[packethandling.Format]uint8{
+		packethandling.FormatUnknown:         0,
+		packethandling.FormatMotorsport7Sled: 1,
+		packethandling.FormatMotorsport7Dash: 2,
+		packethandling.FormatHorizon:         3,
+		packethandling.FormatMotorsport2023:  4,
+	}
+	for format, code := range want {
+		if got := encodeFormat(format); got != code {
+			t.Errorf("%s written as %d, want %d", format, got, code)
+		}
+		if got := decodeFormat(code); got != format {
+			t.Errorf("%d read as %s, want %s", code, got, format)
+		}
+	}
+	if got := decodeFormat(200); got != packethandling.FormatUnknown {
+		t.Errorf("a code from a newer version read as %s", got)
+	}
+}
+
+// A bad CRC or garbage between records is skipped and reading carries on after it
+func TestDamage(t *testing.T) {
+	var buf bytes.Buffer
+	packets := session(20)
+	write(t, &buf, 0, packets, nil)
+	clean := buf.Bytes()
+	metaLen := int(le.Uint32(clean[10:]))
+	recordAt := func(i int) int { return 14 + metaLen + i*(recordHeaderSize+324) }
+
+	tests := []struct {
+		name    string
+		damage  func(b []byte) []byte
+		missing []int // Packets lost
+		damaged int   // Stretches reported
+		trailer bool
+	}{
+		{"payload", func(b []byte) []byte { b[recordAt(5)+100] ^= 0xff; return b }, []int{5}, 1, true},
+		{"record header", func(b []byte) []byte { b[recordAt(7)+3] ^= 0x01; return b }, []int{7}, 1, true},
+		{"sync marker", func(b []byte) []byte { b[recordAt(3)] = 0; return b }, []int{3}, 1, true},
+		{"two stretches", func(b []byte) []byte { b[recordAt(2)+20] ^= 1; b[recordAt(12)+20] ^= 1; return b }, []int{2, 12}, 2, true},
+		{"garbage between records", func(b []byte) []byte {
+			at := recordAt(10)
+			return append(b[:at:at], append([]byte("FZ garbage FZ"), b[at:]...)...)
+		}, nil, 1, true},
+		{"cut off mid record", func(b []byte) []byte { return b[:recordAt(15)+50] }, []int{15, 16, 17, 18, 19}, 1, false},
+		{"cut off between records", func(b []byte) []byte { return b[:recordAt(15)] }, []int{15, 16, 17, 18, 19}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.damage(bytes.Clone(clean))
+			r, err := NewReader(bytes.NewReader(b), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, damage := readAll(t, r)
+
+			var want [][]byte
+			for i, p := range packets {
+				if !slices.Contains(tt.missing, i) {
+					want = append(want, p)
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %d packets, want %d", len(got), len(want))
+			}
+			if stretches, _ := r.Skipped(); stretches != tt.damaged || len(damage) != tt.damaged {
+				t.Errorf("Skipped says %d stretches and got %d damage errors, want %d", stretches, len(damage), tt.damaged)
+			}
+			if _, ok := r.Trailer(); ok != tt.trailer {
+				t.Errorf("trailer %t, want %t", ok, tt.trailer)
+			}
+		})
+	}
+}
+
+// Files from before the container are bare packets, read with the size given
+// writeMembers writes a gzipped recording, flushing every 5 packets so each
+// 5 is a gzip member, and returns where each member starts
+func writeMembers(t *testing.T, packets [][]byte) ([]byte, []int) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Format: packethandling.FormatHorizon, PacketSize: packethandling.FormatHorizon.Size(), Flags: FlagGzip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	members := []int{buf.Len()}
+	for i, p := range packets {
+		if err := w.WritePacket(p, received(i)); err != nil {
+			t.Fatal(err)
+		}
+		if i%5 == 4 {
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			members = append(members, buf.Len())
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), members
+}
+
+// Damage to a gzipped recording loses the gzip member it's in, decompressing
+// picks up again at the next one
+func TestGzipDamage(t *testing.T) {
+	packets := session(20)
+	clean, members := writeMembers(t, packets)
+	if len(members) != 5 {
+		t.Fatalf("%d members", len(members))
+	}
+	middle := func(m int) int { return (members[m] + members[m+1]) / 2 }
+
+	tests := []struct {
+		name    string
+		damage  func(b []byte) []byte
+		kept    []int // Packets that have to survive, nil for all
+		maybe   []int // Packets that can go either way
+		trailer bool
+	}{
+		{"compressed data", func(b []byte) []byte {
+			for i := range 8 {
+				b[middle(1)+i] ^= 0x55
+			}
+			return b
+		}, []int{0, 1, 2, 3, 4, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, []int{5, 6, 7, 8, 9}, true},
+		{"member header", func(b []byte) []byte { b[members[2]] = 0; return b },
+			[]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 15, 16, 17, 18, 19}, nil, true},
+		{"member checksum", func(b []byte) []byte { b[members[2]-8] ^= 1; return b }, nil, nil, true},
+		{"two members", func(b []byte) []byte { b[members[1]] = 0; b[members[3]] = 0; return b },
+			[]int{0, 1, 2, 3, 4, 10, 11, 12, 13, 14}, nil, true},
+		{"cut off", func(b []byte) []byte { return b[:middle(3)] },
+			[]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}, []int{15, 16, 17, 18, 19}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.damage(bytes.Clone(clean))), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, damage := readAll(t, r)
+			if len(damage) == 0 {
+				t.Error("no damage reported")
+			}
+
+			var want, most [][]byte
+			for i, p := range packets {
+				if tt.kept == nil || slices.Contains(tt.kept, i) {
+					want = append(want, p)
+				}
+				if tt.kept == nil || slices.Contains(tt.kept, i) || slices.Contains(tt.maybe, i) {
+					most = append(most, p)
+				}
+			}
+			// Everything kept, in order, and nothing that isn't in the recording
+			kept := slices.DeleteFunc(slices.Clone(got), func(p []byte) bool {
+				return !slices.ContainsFunc(want, func(w []byte) bool { return bytes.Equal(p, w) })
+			})
+			if !reflect.DeepEqual(kept, want) || len(got) > len(most) {
+				t.Fatalf("got %d packets, want %d to %d", len(got), len(want), len(most))
+			}
+			for _, p := range got {
+				if !slices.ContainsFunc(most, func(w []byte) bool { return bytes.Equal(p, w) }) {
+					t.Fatal("got a packet that was lost")
+				}
+			}
+			if _, ok := r.Trailer(); ok != tt.trailer {
+				t.Errorf("trailer %t, want %t", ok, tt.trailer)
+			}
+		})
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	packets := session(10)
+	raw := bytes.Join(packets, nil)
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	zw.Write(raw)
+	zw.Close()
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{{"plain", raw}, {"gzip", zipped.Bytes()}} {
+		r, err := NewReader(bytes.NewReader(tt.data), 324)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.Legacy() || r.Header().Format != packethandling.FormatHorizon || r.Header().Version != 0 {
+			t.Fatalf("%s: got header %+v", tt.name, r.Header())
+		}
+		got, times, damage := readAll(t, r)
+		if !reflect.DeepEqual(got, packets) || len(damage) > 0 || !times[0].IsZero() {
+			t.Fatalf("%s: got %d packets, %v", tt.name, len(got), damage)
+		}
+	}
+
+	// A cut off last packet is reported and the rest kept
+	r, _ := NewReader(bytes.NewReader(raw[:len(raw)-10]), 324)
+	got, _, damage := readAll(t, r)
+	if len(got) != 9 || len(damage) != 1 || damage[0].Skipped != 314 {
+		t.Fatalf("cut off: got %d packets and %v", len(got), damage)
+	}
+
+	if _, err := NewReader(bytes.NewReader(raw), 100); err == nil {
+		t.Fatal("no error for a size that isn't a Forza packet")
+	}
+}
+
+func TestIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets := session(200)
+	var x Indexer
+	write(t, f, FlagGzip, packets, &x)
+	f.Close()
+	built := x.Index()
+
+	if _, err := LoadIndex(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got %v with no index, want os.ErrNotExist", err)
+	}
+	if err := built.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entries(loaded), entries(built)) {
+		t.Fatalf("loaded %v, want %v", loaded.Entries, built.Entries)
+	}
+	rebuilt, err := BuildIndex(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entries(rebuilt), entries(built)) {
+		t.Fatalf("BuildIndex gave %v, want %v", rebuilt.Entries, built.Entries)
+	}
+
+	// First packet, race start, lap, race end, and a checkpoint every second
+	var events []Event
+	for _, e := range built.Entries {
+		if e.Event != EventCheckpoint {
+			events = append(events, e.Event)
+		}
+	}
+	if !reflect.DeepEqual(events, []Event{EventRaceStart, EventLap, EventRaceEnd}) {
+		t.Errorf("got events %v", events)
+	}
+	// The race events count as marks too, so after the one at 0.8s they're at 1.8s and 2.8s
+	if n := len(built.Events(EventCheckpoint)); n != 3 {
+		t.Errorf("got %d checkpoints over 3.2s, want 3", n)
+	}
+
+	// Seeking to an entry lands on its packet
+	lap, ok := built.Lap(1)
+	if !ok || lap.Record != 30 {
+		t.Fatalf("lap 1 at %+v, %t", lap, ok)
+	}
+	r, err := Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, e := range []Entry{built.Entries[len(built.Entries)-1], lap, built.Entries[0]} {
+		if err := r.SeekOffset(e.Offset); err != nil {
+			t.Fatal(err)
+		}
+		rec, err := r.NextPacket()
+		if err != nil || !bytes.Equal(rec.Data, packets[e.Record]) {
+			t.Fatalf("seek to packet %d: %v", e.Record, err)
+		}
+	}
+
+	// Changing the recording makes the index stale, OpenIndex rebuilds it
+	f, _ = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.Write([]byte{0})
+	f.Close()
+	if _, err := LoadIndex(path); !errors.Is(err, ErrStaleIndex) {
+		t.Fatalf("got %v after the recording changed, want ErrStaleIndex", err)
+	}
+	if _, err := OpenIndex(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadIndex(path); err != nil {
+		t.Fatalf("OpenIndex didn't save a fresh index: %v", err)
+	}
+
+	// So does rewriting it at the same size
+	f, _ = os.OpenFile(path, os.O_WRONLY, 0)
+	f.WriteAt([]byte{1}, 100)
+	f.Close()
+	info, _ := os.Stat(path)
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadIndex(path); !errors.Is(err, ErrStaleIndex) {
+		t.Fatalf("got %v after the recording was rewritten, want ErrStaleIndex", err)
+	}
+
+	// And an index from another version
+	if _, err := OpenIndex(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(IndexPath(path))
+	le.PutUint16(b[4:], indexVersion-1)
+	os.WriteFile(IndexPath(path), b, 0o644)
+	if _, err := LoadIndex(path); !errors.Is(err, ErrStaleIndex) {
+		t.Fatalf("got %v for an old index, want ErrStaleIndex", err)
+	}
+}
+
+// entries drops the monotonic clock readings so loaded and built entries compare
+func entries(ix *Index) []Entry {
+	out := make([]Entry, len(ix.Entries))
+	for i, e := range ix.Entries {
+		e.Received = time.Unix(0, e.Received.UnixNano())
+		out[i] = e
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package recording
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxGap is the longest pause kept between two packets on a Timeline,
+	// anything longer (left running in a menu, or the clock jumped) counts as one packet interval
+	DefaultMaxGap = 2 * time.Second
+
+	packetInterval = time.Second / 60
+)
+
+// Clock is which packet times a Timeline is built from
+type Clock int
+
+const (
+	ClockAuto      Clock = iota // Receive times if the packet has one, otherwise TimeStampMS
+	ClockReceived               // The receive times stored in the recording
+	ClockTimestamp              // The game's TimeStampMS
+)
+
+func (c Clock) String() string {
+	switch c {
+	case ClockAuto:
+		return "auto"
+	case ClockReceived:
+		return "received"
+	case ClockTimestamp:
+		return "timestamp"
+	default:
+		return fmt.Sprintf("Clock(%d)", int(c))
+	}
+}
+
+// ParseClock parses "auto", "received" or "timestamp"
+func ParseClock(s string) (Clock, error) {
+	for _, c := range []Clock{ClockAuto, ClockReceived, ClockTimestamp} {
+		if strings.EqualFold(s, c.String()) {
+			return c, nil
+		}
+	}
+	return ClockAuto, fmt.Errorf("unknown clock %q, expected auto, received or timestamp", s)
+}
+
+// Timeline works out how far into a recording each packet is from the gaps
+// between them. The zero value uses ClockAuto and DefaultMaxGap.
+type Timeline struct {
+	Clock  Clock
+	MaxGap time.Duration // <= 0 uses DefaultMaxGap
+
+	elapsed      time.Duration
+	prevReceived time.Time
+	prevStamp    uint32
+	started      bool
+}
+
+// Advance adds the next packet and returns its time into the recording.
+// stamp is its TimeStampMS, 0 if it has none or didn't parse.
+func (t *Timeline) Advance(received time.Time, stamp uint32) time.Duration {
+	var gap time.Duration = -1
+	if t.Clock == ClockReceived || (t.Clock == ClockAuto && !received.IsZero()) {
+		if !received.IsZero() && !t.prevReceived.IsZero() {
+			gap = received.Sub(t.prevReceived)
+		}
+		t.prevReceived = received
+	} else if stamp != 0 {
+		if t.prevStamp != 0 {
+			gap = time.Duration(int32(stamp-t.prevStamp)) * time.Millisecond
+		}
+		t.prevStamp = stamp
+	}
+
+	maxGap := t.MaxGap
+	if maxGap <= 0 {
+		maxGap = DefaultMaxGap
+	}
+	switch {
+	case !t.started:
+		gap = 0
+	case gap < 0 || gap > maxGap:
+		gap = packetInterval
+	}
+	t.elapsed += gap
+	t.started = true
+	return t.elapsed
+}
+
+// Elapsed is the time of the last packet added
+func (t *Timeline) Elapsed() time.Duration {
+	return t.elapsed
+}
+
+// Restart starts again with the next packet at elapsed, e.g. after jumping to an index entry
+func (t *Timeline) Restart(elapsed time.Duration) {
+	t.elapsed = elapsed
+	t.prevReceived = time.Time{}
+	t.prevStamp = 0
+	t.started = false
+}
@@ -0,0 +1,190 @@
+package recording
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/sequencing"
+	"io"
+	"os"
+	"time"
+)
+
+// Writer writes a recording. It buffers, so Sync or Flush it as it goes and
+// Close it when done, which writes the trailer.
+type Writer struct {
+	w      *bufio.Writer
+	zw     *gzip.Writer // nil unless FlagGzip
+	body   io.Writer    // Where records go, zw or w
+	syncer syncer       // nil if what's written to can't be synced
+	closer io.Closer
+	head   [recordHeaderSize]byte
+	count  int
+	offset int64
+	member int64     // Offset the current gzip member starts at
+	last   time.Time // Receive time of the last record
+	health *sequencing.Stats
+}
+
+type syncer interface {
+	Sync() error
+}
+
+// NewWriter writes the header to w. Version is always set to the current one.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	return newWriter(w, nil, h)
+}
+
+// Create makes a new recording file, Close closes the file
+func Create(path string, h Header) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	rw, err := newWriter(f, f, h)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return rw, nil
+}
+
+func newWriter(w io.Writer, closer io.Closer, h Header) (*Writer, error) {
+	meta, err := json.Marshal(h.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("recording: metadata: %w", err)
+	}
+
+	header := make([]byte, headerSize, headerSize+len(meta))
+	copy(header, magic)
+	le.PutUint16(header[4:], Version)
+	header[6] = encodeFormat(h.Format)
+	le.PutUint16(header[7:], uint16(h.PacketSize))
+	header[9] = uint8(h.Flags)
+	le.PutUint32(header[10:], uint32(len(meta)))
+	header = append(header, meta...)
+
+	bw := bufio.NewWriterSize(w, 64*1024)
+	if _, err := bw.Write(header); err != nil {
+		return nil, err
+	}
+
+	rw := &Writer{w: bw, body: bw, closer: closer}
+	rw.syncer, _ = w.(syncer)
+	if h.Flags&FlagGzip != 0 {
+		rw.zw = gzip.NewWriter(bw)
+		rw.body = rw.zw
+	}
+	return rw, nil
+}
+
+// WritePacket writes a datagram and when it was received
+func (w *Writer) WritePacket(data []byte, received time.Time) error {
+	return w.WriteRecord(Record{Kind: KindPacket, Received: received, Data: data})
+}
+
+// WriteRecord writes any record
+func (w *Writer) WriteRecord(r Record) error {
+	if len(r.Data) > MaxPayload {
+		return fmt.Errorf("recording: %d byte record is too big", len(r.Data))
+	}
+
+	var nanos int64
+	if !r.Received.IsZero() {
+		nanos = r.Received.UnixNano()
+	}
+
+	h := w.head[:]
+	le.PutUint16(h[0:], syncMarker)
+	h[2] = uint8(r.Kind)
+	le.PutUint16(h[3:], uint16(len(r.Data)))
+	le.PutUint64(h[5:], uint64(nanos))
+	le.PutUint32(h[13:], recordChecksum(h, r.Data))
+
+	if _, err := w.body.Write(h); err != nil {
+		return err
+	}
+	if _, err := w.body.Write(r.Data); err != nil {
+		return err
+	}
+	w.count++
+	w.offset += int64(len(h) + len(r.Data))
+	if !r.Received.IsZero() {
+		w.last = r.Received
+	}
+	return nil
+}
+
+// Offset is where the next record goes, counted from the first record like Reader.Offset
+func (w *Writer) Offset() int64 {
+	return w.offset
+}
+
+// Count is how many records have been written
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Flush writes anything buffered. Compressed recordings end the gzip member
+// they're on and start another, so a reader can pick up again at the next one
+// after damage. It costs a little compression.
+func (w *Writer) Flush() error {
+	if w.zw != nil && w.offset != w.member {
+		if err := w.zw.Close(); err != nil {
+			return err
+		}
+		w.zw.Reset(w.w)
+		w.member = w.offset
+	}
+	return w.w.Flush()
+}
+
+// Sync flushes and then has the OS write the file to disk, so everything so far
+// survives a crash or power cut. It's slow, so call it every second or so.
+func (w *Writer) Sync() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if w.syncer == nil {
+		return nil
+	}
+	return w.syncer.Sync()
+}
+
+// SetHealth stores the stream health in the trailer, call it before Close
+func (w *Writer) SetHealth(s sequencing.Stats) {
+	w.health = &s
+}
+
+// Close writes the trailer, flushes and syncs, and closes the file if the Writer
+// opened it. Without the sync a power cut after a clean exit could still lose the trailer.
+func (w *Writer) Close() error {
+	t := Trailer{Records: w.count, Health: w.health}
+	if !w.last.IsZero() {
+		t.End = &w.last
+	}
+	trailer, err := json.Marshal(t)
+	if err == nil {
+		err = w.WriteRecord(Record{Kind: KindTrailer, Received: w.last, Data: trailer})
+	}
+	if w.zw != nil {
+		if zerr := w.zw.Close(); err == nil {
+			err = zerr
+		}
+	}
+	if ferr := w.w.Flush(); err == nil {
+		err = ferr
+	}
+	if w.syncer != nil {
+		if serr := w.syncer.Sync(); err == nil {
+			err = serr
+		}
+	}
+	if w.closer != nil {
+		if cerr := w.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/sequencing"
+	"io"
+	"testing"
+	"time"
+)
+
+// The stream health given to the Writer comes back from the trailer
+func TestTrailerHealth(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Format: packethandling.FormatHorizon, PacketSize: packethandling.FormatHorizon.Size()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := time.Unix(1700000000, 0)
+	if err := w.WritePacket(make([]byte, packethandling.FormatHorizon.Size()), received); err != nil {
+		t.Fatal(err)
+	}
+	health := sequencing.Stats{Packets: 1, Lost: 3, Duplicates: 1, LossPercent: 75, Jitter: 2 * time.Millisecond, LastPacket: received}
+	w.SetHealth(health)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(&buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := r.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	trailer, ok := r.Trailer()
+	if !ok || trailer.Records != 1 || trailer.Health == nil {
+		t.Fatalf("got trailer %+v, %t", trailer, ok)
+	}
+	got := *trailer.Health
+	if !got.LastPacket.Equal(received) {
+		t.Errorf("last packet %s, want %s", got.LastPacket, received)
+	}
+	got.LastPacket = health.LastPacket
+	if got != health {
+		t.Errorf("got health %+v, want %+v", got, health)
+	}
+}
+
+// Every Flush of a gzipped recording ends the gzip member, but one with nothing
+// new doesn't start an empty one
+func TestGzipMembers(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Format: packethandling.FormatHorizon, PacketSize: packethandling.FormatHorizon.Size(), Flags: FlagGzip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := make([]byte, packethandling.FormatHorizon.Size())
+	for range 3 {
+		if err := w.WritePacket(packet, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		for range 2 {
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Three for the packets and one for the trailer
+	body := bufio.NewReader(bytes.NewReader(buf.Bytes()[headerSize+le.Uint32(buf.Bytes()[10:]):]))
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := 0
+	for {
+		zr.Multistream(false)
+		n, err := io.Copy(io.Discard, zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			t.Errorf("member %d is empty", members)
+		}
+		members++
+		if err := zr.Reset(body); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if members != 4 {
+		t.Errorf("got %d gzip members, want 4", members)
+	}
+}
+
+// Without receive times the trailer has no end rather than the zero time
+func TestTrailerNoEnd(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Format: packethandling.FormatHorizon, PacketSize: packethandling.FormatHorizon.Size()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePacket(make([]byte, packethandling.FormatHorizon.Size()), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []byte(`{"records":1}`); !bytes.HasSuffix(buf.Bytes(), want) {
+		t.Errorf("trailer doesn't end the file as %s", want)
+	}
+	r, err := NewReader(&buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := r.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if trailer, ok := r.Trailer(); !ok || trailer.End != nil {
+		t.Fatalf("got trailer %+v, %t", trailer, ok)
+	}
+}
+
+// syncBuffer records how much had been written each time it was synced
+type syncBuffer struct {
+	bytes.Buffer
+	synced []int
+}
+
+func (b *syncBuffer) Sync() error {
+	b.synced = append(b.synced, b.Len())
+	return nil
+}
+
+// Close syncs after the trailer so a power cut straight after can't lose it
+func TestCloseSyncs(t *testing.T) {
+	var buf syncBuffer
+	w, err := NewWriter(&buf, Header{Format: packethandling.FormatHorizon, PacketSize: packethandling.FormatHorizon.Size()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePacket(make([]byte, packethandling.FormatHorizon.Size()), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(buf.synced) != 1 || buf.synced[0] != buf.Len() {
+		t.Fatalf("synced at %v of %d bytes, want once at the end", buf.synced, buf.Len())
+	}
+}
@@ -0,0 +1,580 @@
+// Package replay plays recordings back at the speed they were recorded, with
+// pause, step, seek and speed control. A Player is a PacketSource, so anything
+// that reads live packets can read a replay instead.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/recording"
+	"forza-horizon-5-telemetry/shared/source"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	MinSpeed = 0.25
+	MaxSpeed = 8
+
+	// DefaultMaxGap is the longest pause kept between two packets, anything longer
+	// (the recording was left running in a menu, or the clock jumped) plays as one packet interval
+	DefaultMaxGap = recording.DefaultMaxGap
+
+	// Running further behind than this (a slow reader, or the machine slept) starts
+	// the clock again from the current packet instead of rushing to catch up
+	maxLateness = 250 * time.Millisecond
+)
+
+// ErrNotFound is returned by a seek that doesn't match anything
+var ErrNotFound = errors.New("replay: seek target not in recording")
+
+// Clock is which times are used to pace the replay
+type Clock = recording.Clock
+
+const (
+	ClockAuto      = recording.ClockAuto      // Receive times if the recording has them, otherwise TimeStampMS
+	ClockReceived  = recording.ClockReceived  // The receive times stored in the recording
+	ClockTimestamp = recording.ClockTimestamp // The game's TimeStampMS
+)
+
+// ParseClock parses "auto", "received" or "timestamp"
+func ParseClock(s string) (Clock, error) {
+	return recording.ParseClock(s)
+}
+
+// Options control a Player
+type Options struct {
+	Clock  Clock
+	Speed  float64       // 1 is real time, clamped to MinSpeed - MaxSpeed. 0 means 1.
+	Loop   bool          // Start again at the end
+	MaxGap time.Duration // <= 0 uses DefaultMaxGap
+
+	// HoldAtEnd makes Next wait for a seek at the end instead of returning
+	// io.EOF, for players someone is controlling. Ignored if Loop is set.
+	HoldAtEnd bool
+}
+
+// Status is where the replay is up to
+type Status struct {
+	Position time.Duration // Time into the recording of the last packet played
+	Speed    float64
+	Paused   bool
+	Ended    bool
+	Loops    int    // Times it's gone back to the start
+	Lap      uint16 // LapNumber of the last packet played
+	HasLap   bool   // False until a packet with a dash section is played
+}
+
+// Player paces packets from a source that can be opened again from the start.
+// Next is meant to be called from one goroutine, the controls from any.
+type Player struct {
+	name string
+	open func() (source.PacketSource, error)
+	opts Options
+
+	mu    sync.Mutex
+	src   source.PacketSource
+	index *recording.Index // nil without one, seeks read through the recording instead
+
+	// The packet read ahead that's waiting for its time to come
+	pending   *source.Packet
+	next      packethandling.ForzaHorizon5Packet
+	nextOK    bool          // next parsed
+	nextMedia time.Duration // Time into the recording of pending
+
+	// A copy of what Next last returned. Sources reuse their buffer and seeks
+	// read from them on other goroutines while it's still being used.
+	delivered []byte
+
+	timeline recording.Timeline
+	started  bool // A packet has been read since the last open
+
+	position time.Duration
+	lap      uint16
+	hasLap   bool
+	loops    int
+	ended    bool
+
+	speed       float64
+	paused      bool
+	steps       int
+	anchorWall  time.Time     // Wall time that anchorMedia plays at
+	anchorMedia time.Duration // Recording time at anchorWall
+
+	wake chan struct{}
+}
+
+// New makes a Player, open is called to read the recording from the start
+// (once now and again on every loop or seek backwards)
+func New(name string, open func() (source.PacketSource, error), opts Options) (*Player, error) {
+	src, err := open()
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxGap <= 0 {
+		opts.MaxGap = DefaultMaxGap
+	}
+	p := &Player{name: name, open: open, opts: opts, src: src, wake: make(chan struct{}, 1)}
+	p.speed = clampSpeed(opts.Speed)
+	p.timeline = recording.Timeline{Clock: opts.Clock, MaxGap: opts.MaxGap}
+	return p, nil
+}
+
+// OpenFile replays a recording or capture, see source.OpenFile. Recordings are
+// indexed so seeks can jump straight to the right place, the index is saved
+// next to the recording the first time.
+func OpenFile(path string, legacySize int, opts Options) (*Player, error) {
+	p, err := New(path, func() (source.PacketSource, error) {
+		return source.OpenFile(path, legacySize, false)
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.src.(*source.Recording); ok {
+		// Not being able to save it (e.g. a read only folder) just means building it again next time
+		if ix, _ := recording.OpenIndex(path, legacySize); ix != nil {
+			p.index = ix
+		}
+	}
+	return p, nil
+}
+
+func clampSpeed(s float64) float64 {
+	if s == 0 {
+		return 1
+	}
+	return min(max(s, MinSpeed), MaxSpeed)
+}
+
+// Next waits until the next packet is due and returns it
+func (p *Player) Next(ctx context.Context) (source.Packet, error) {
+	for {
+		p.mu.Lock()
+		if p.pending == nil && !p.ended {
+			err := p.readNext(ctx, p.opts.Loop)
+			if err == io.EOF {
+				p.ended = true
+			} else if err != nil {
+				p.mu.Unlock()
+				return source.Packet{}, err
+			}
+		}
+		if p.ended {
+			p.mu.Unlock()
+			if !p.opts.HoldAtEnd {
+				return source.Packet{}, io.EOF
+			}
+			// Wait for a seek to give us something to play
+			select {
+			case <-ctx.Done():
+				return source.Packet{}, ctx.Err()
+			case <-p.wake:
+			}
+			continue
+		}
+
+		now := time.Now()
+		if p.anchorWall.IsZero() {
+			p.reanchor(now, p.nextMedia)
+		}
+
+		// wait is how long until the packet is due, 0 without deliver waits for a control to change
+		var wait time.Duration
+		deliver := false
+		switch {
+		case p.paused && p.steps > 0:
+			p.steps--
+			deliver = true
+		case p.paused:
+		default:
+			wait = p.anchorWall.Add(time.Duration(float64(p.nextMedia-p.anchorMedia) / p.speed)).Sub(now)
+			if wait < -maxLateness {
+				p.reanchor(now, p.nextMedia)
+			}
+			deliver = wait <= 0
+		}
+
+		if deliver {
+			pkt := p.deliver()
+			p.mu.Unlock()
+			return pkt, nil
+		}
+		p.mu.Unlock()
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-p.wake:
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return source.Packet{}, err
+		}
+	}
+}
+
+// deliver hands over the pending packet, the lock must be held
+func (p *Player) deliver() source.Packet {
+	pkt := *p.pending
+	p.delivered = append(p.delivered[:0], pkt.Data...)
+	pkt.Data = p.delivered
+	p.pending = nil
+	p.position = p.nextMedia
+	if p.nextOK && p.next.Format.HasDash() {
+		p.lap, p.hasLap = p.next.LapNumber, true
+	}
+	return pkt
+}
+
+// readNext reads the next packet into pending and works out its place on the
+// timeline. At the end it opens the source again if loop is set.
+func (p *Player) readNext(ctx context.Context, loop bool) error {
+	pkt, err := p.src.Next(ctx)
+	if err == io.EOF && loop && p.started {
+		if err := p.reopen(); err != nil {
+			return err
+		}
+		p.loops++
+		p.anchorWall = time.Time{}
+		pkt, err = p.src.Next(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	p.nextOK = packethandling.ParsePacket(pkt.Data, &p.next) == nil
+
+	var stamp uint32
+	if p.nextOK {
+		stamp = p.next.TimeStampMS
+	}
+	p.nextMedia = p.timeline.Advance(pkt.Received, stamp)
+	p.started = true
+
+	p.pending = &pkt
+	return nil
+}
+
+// reopen goes back to the start of the recording, the lock must be held
+func (p *Player) reopen() error {
+	src, err := p.open()
+	if err != nil {
+		return err
+	}
+	p.src.Close()
+	p.src = src
+
+	p.pending = nil
+	p.timeline.Restart(0)
+	p.started = false
+	p.ended = false
+	return nil
+}
+
+// jump reopens the recording at an index entry, returning false if it can't
+// and seeks have to read from the start instead. The lock must be held.
+func (p *Player) jump(e recording.Entry) (bool, error) {
+	// The index's times are from a default timeline, anything else would put us in the wrong place
+	if p.index == nil || p.opts.Clock != ClockAuto || p.opts.MaxGap != DefaultMaxGap {
+		return false, nil
+	}
+	if _, ok := p.src.(entrySeeker); !ok {
+		return false, nil
+	}
+
+	if err := p.reopen(); err != nil {
+		return false, err
+	}
+	if err := p.src.(entrySeeker).Seek(e); err != nil {
+		return false, err
+	}
+	p.timeline.Restart(e.Elapsed)
+	p.position = e.Elapsed
+	return true, nil
+}
+
+// entrySeeker is a source that can jump to an index entry, i.e. a recording file
+type entrySeeker interface {
+	Seek(e recording.Entry) error
+}
+
+func (p *Player) reanchor(now time.Time, media time.Duration) {
+	p.anchorWall = now
+	p.anchorMedia = media
+}
+
+func (p *Player) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pause stops the clock
+func (p *Player) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = true
+	p.steps = 0
+}
+
+// Resume carries on from the last packet played
+func (p *Player) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		p.paused = false
+		p.reanchor(time.Now(), p.position)
+	}
+	p.notify()
+}
+
+// TogglePause pauses or resumes, returns true if now paused
+func (p *Player) TogglePause() bool {
+	p.mu.Lock()
+	paused := p.paused
+	p.mu.Unlock()
+
+	if paused {
+		p.Resume()
+	} else {
+		p.Pause()
+	}
+	return !paused
+}
+
+// Step plays exactly one packet, pausing first if playing
+func (p *Player) Step() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = true
+	p.steps++
+	p.notify()
+}
+
+// SetSpeed changes the speed, clamped to MinSpeed - MaxSpeed. Returns the speed set.
+func (p *Player) SetSpeed(speed float64) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.speed = clampSpeed(speed)
+	p.reanchor(time.Now(), p.position)
+	p.notify()
+	return p.speed
+}
+
+// Faster doubles the speed
+func (p *Player) Faster() float64 {
+	return p.SetSpeed(p.Status().Speed * 2)
+}
+
+// Slower halves the speed
+func (p *Player) Slower() float64 {
+	return p.SetSpeed(p.Status().Speed / 2)
+}
+
+// SeekTime jumps to the first packet at or after d into the recording.
+// Past the end stops at the last packet.
+func (p *Player) SeekTime(d time.Duration) error {
+	d = max(d, 0)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fromStart := d <= p.position
+	if p.index != nil {
+		if e, ok := p.index.AtElapsed(d); ok && (fromStart || e.Elapsed > p.position) {
+			jumped, err := p.jump(e)
+			if err != nil {
+				return err
+			}
+			fromStart = fromStart && !jumped
+		}
+	}
+
+	err := p.seek(fromStart, func() bool { return p.nextMedia >= d })
+	if err == ErrNotFound {
+		err = nil // Sat on the last packet
+	}
+	return err
+}
+
+// SeekLap jumps to the first packet of a lap while racing, by LapNumber
+func (p *Player) SeekLap(lap uint16) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	match := func() bool {
+		return p.nextOK && p.next.IsRaceOn != 0 && p.next.Format.HasDash() && p.next.LapNumber == lap
+	}
+
+	fromStart := !p.hasLap || lap <= p.lap
+	if p.index != nil {
+		e, ok := p.index.Lap(lap)
+		if !ok {
+			return fmt.Errorf("%w: no lap %d", ErrNotFound, lap)
+		}
+		jumped, err := p.jump(e)
+		if err != nil {
+			return err
+		}
+		fromStart = fromStart && !jumped
+	}
+
+	if err := p.seekOrStay(fromStart, match); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("%w: no lap %d", ErrNotFound, lap)
+		}
+		return err
+	}
+	return nil
+}
+
+// SeekRaceTime jumps to t seconds of CurrentRaceTime into the first race that gets that far
+func (p *Player) SeekRaceTime(t float32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	match := func() bool {
+		return p.nextOK && p.next.IsRaceOn != 0 && p.next.Format.HasDash() && p.next.CurrentRaceTime >= t
+	}
+
+	fromStart := true
+	if p.index != nil {
+		e, ok := p.index.AtRaceTime(t)
+		if !ok {
+			return fmt.Errorf("%w: no race gets to %.1fs", ErrNotFound, t)
+		}
+		jumped, err := p.jump(e)
+		if err != nil {
+			return err
+		}
+		fromStart = !jumped
+	}
+
+	if err := p.seekOrStay(fromStart, match); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("%w: no race gets to %.1fs", ErrNotFound, t)
+		}
+		return err
+	}
+	return nil
+}
+
+// seekOrStay is seek, but goes back to where it was if nothing matches rather
+// than leaving it at the end. The lock must be held.
+func (p *Player) seekOrStay(fromStart bool, match func() bool) error {
+	// Back to the packet that was next: the pending one, or the one after the last delivered
+	from, after := p.position, p.started
+	if p.pending != nil {
+		from, after = p.nextMedia, false
+	}
+	err := p.seek(fromStart, match)
+	if err == ErrNotFound {
+		back := func() bool { return p.nextMedia > from || (!after && p.nextMedia == from) }
+		if serr := p.seek(true, back); serr != nil && serr != ErrNotFound {
+			return serr
+		}
+	}
+	return err
+}
+
+// seek reads forward (from the start if fromStart) until match is true for the
+// pending packet. Without a match it's left on the last packet. The lock must be held.
+func (p *Player) seek(fromStart bool, match func() bool) error {
+	defer p.notify()
+
+	if fromStart {
+		if err := p.reopen(); err != nil {
+			return err
+		}
+	}
+
+	var last *source.Packet
+	for {
+		if p.pending == nil {
+			err := p.readNext(context.Background(), false)
+			if err == io.EOF {
+				break
+			}
+			var damage *recording.DamageError
+			if errors.As(err, &damage) {
+				continue // Seek past it
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if match() {
+			p.ended = false
+			p.position = p.nextMedia
+			p.reanchor(time.Now(), p.nextMedia)
+			if p.paused {
+				p.steps = 1 // Show where we landed
+			}
+			return nil
+		}
+
+		// Hang on to the packet so there's something to stop at if nothing matches.
+		// Its data gets reused by the next read, so copy it.
+		pkt := *p.pending
+		pkt.Data = append([]byte(nil), pkt.Data...)
+		last = &pkt
+		p.position = p.nextMedia
+		p.pending = nil
+	}
+
+	if last != nil {
+		p.pending = last
+		p.nextMedia = p.position
+		p.nextOK = packethandling.ParsePacket(last.Data, &p.next) == nil
+		p.ended = false
+		p.reanchor(time.Now(), p.position)
+		if p.paused {
+			p.steps = 1
+		}
+	}
+	return ErrNotFound
+}
+
+// Idle returns true while Next is holding packets back on purpose, paused or
+// waiting at the end, so a quiet replay isn't taken for a stalled one
+func (p *Player) Idle() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return (p.paused && p.steps == 0) || (p.ended && p.opts.HoldAtEnd && !p.opts.Loop)
+}
+
+// Status returns where the replay is up to
+func (p *Player) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return Status{
+		Position: p.position,
+		Speed:    p.speed,
+		Paused:   p.paused,
+		Ended:    p.ended,
+		Loops:    p.loops,
+		Lap:      p.lap,
+		HasLap:   p.hasLap,
+	}
+}
+
+func (p *Player) Name() string {
+	return p.name
+}
+
+func (p *Player) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.src.Close()
+}
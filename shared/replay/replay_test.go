@@ -0,0 +1,347 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/recording"
+	"forza-horizon-5-telemetry/shared/source"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const interval = 16 * time.Millisecond
+
+// session returns n packets 16ms apart by TimeStampMS: menu, then racing from
+// packet 10 with lap 1 from packet 30, then back to the menu from packet 50
+func session(n int) [][]byte {
+	var packets [][]byte
+	for i := range n {
+		p := packethandling.ForzaHorizon5Packet{TimeStampMS: stamp(i)}
+		if i >= 10 && i < 50 {
+			p.IsRaceOn = 1
+			p.CurrentRaceTime = float32(i-10) * 0.016
+		}
+		if i >= 30 {
+			p.LapNumber = 1
+		}
+		packets = append(packets, packethandling.MarshalPacket(&p))
+	}
+	return packets
+}
+
+func stamp(i int) uint32 {
+	return uint32(1000 + 16*i)
+}
+
+// memoryPlayer plays packets from memory, so there's no index and seeks read through
+func memoryPlayer(t *testing.T, packets [][]byte, opts Options) *Player {
+	t.Helper()
+	p, err := New("test", func() (source.PacketSource, error) {
+		return source.NewMemory("test", packets, false), nil
+	}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// filePlayer plays packets from a recording, which gets an index for seeks
+func filePlayer(t *testing.T, packets [][]byte, opts Options) *Player {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := recording.NewWriter(f, recording.Header{Format: packethandling.FormatHorizon, PacketSize: packethandling.FormatHorizon.Size()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range packets {
+		if err := w.WritePacket(data, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	p, err := OpenFile(path, 0, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.index == nil {
+		t.Fatal("no index")
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// next returns the TimeStampMS of the next packet, waiting at most timeout
+func next(p *Player, timeout time.Duration) (uint32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	pkt, err := p.Next(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var parsed packethandling.ForzaHorizon5Packet
+	if err := packethandling.ParsePacket(pkt.Data, &parsed); err != nil {
+		return 0, err
+	}
+	return parsed.TimeStampMS, nil
+}
+
+func mustNext(t *testing.T, p *Player, want uint32) {
+	t.Helper()
+	got, err := next(p, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("got packet %d, want %d", got, want)
+	}
+}
+
+func TestPacing(t *testing.T) {
+	const n = 20
+	for _, speed := range []float64{1, 4} {
+		p := memoryPlayer(t, session(n), Options{Speed: speed})
+		began := time.Now()
+		for i := range n {
+			mustNext(t, p, stamp(i))
+		}
+		took := time.Since(began)
+
+		want := time.Duration(float64((n-1)*interval) / speed)
+		if took < want*8/10 || took > want+300*time.Millisecond {
+			t.Errorf("speed %g: %d packets took %s, want about %s", speed, n, took, want)
+		}
+		if pos := p.Status().Position; pos != (n-1)*interval {
+			t.Errorf("speed %g: position %s, want %s", speed, pos, (n-1)*interval)
+		}
+	}
+}
+
+// A long gap in the recording plays as one packet interval
+func TestMaxGap(t *testing.T) {
+	packets := session(4)
+	p := packethandling.ForzaHorizon5Packet{TimeStampMS: stamp(3) + 60000}
+	packets = append(packets, packethandling.MarshalPacket(&p))
+
+	player := memoryPlayer(t, packets, Options{})
+	began := time.Now()
+	for i := range 4 {
+		mustNext(t, player, stamp(i))
+	}
+	mustNext(t, player, p.TimeStampMS)
+	if took := time.Since(began); took > time.Second {
+		t.Errorf("took %s, the minute long gap wasn't skipped", took)
+	}
+}
+
+func TestPause(t *testing.T) {
+	p := memoryPlayer(t, session(20), Options{})
+	mustNext(t, p, stamp(0))
+
+	p.Pause()
+	if _, err := next(p, 100*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v while paused, want to wait", err)
+	}
+	if !p.Idle() || !p.Status().Paused {
+		t.Fatal("not idle while paused")
+	}
+
+	// Step plays exactly one packet straight away and stays paused
+	p.Step()
+	if p.Idle() {
+		t.Error("idle with a step to play")
+	}
+	began := time.Now()
+	mustNext(t, p, stamp(1))
+	if took := time.Since(began); took > 50*time.Millisecond {
+		t.Errorf("step took %s", took)
+	}
+	if _, err := next(p, 50*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v after a step, want to stay paused", err)
+	}
+
+	// Resume carries on from the last packet rather than rushing through the time spent paused
+	p.TogglePause()
+	if p.Idle() || p.Status().Paused {
+		t.Fatal("still paused after resuming")
+	}
+	began = time.Now()
+	mustNext(t, p, stamp(2))
+	mustNext(t, p, stamp(3))
+	if took := time.Since(began); took < interval {
+		t.Errorf("two packets after resuming took %s, want at least %s", took, interval)
+	}
+}
+
+func TestSeek(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		player func(*testing.T, [][]byte, Options) *Player
+	}{{"reading through", memoryPlayer}, {"index", filePlayer}} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.player(t, session(60), Options{Speed: MaxSpeed})
+			mustNext(t, p, stamp(0))
+
+			if err := p.SeekTime(40 * interval); err != nil {
+				t.Fatal(err)
+			}
+			mustNext(t, p, stamp(40))
+
+			// Backwards
+			if err := p.SeekLap(1); err != nil {
+				t.Fatal(err)
+			}
+			mustNext(t, p, stamp(30))
+			if s := p.Status(); !s.HasLap || s.Lap != 1 || s.Position != 30*interval {
+				t.Fatalf("status %+v after seeking to lap 1", s)
+			}
+
+			if err := p.SeekRaceTime(0.1); err != nil {
+				t.Fatal(err)
+			}
+			mustNext(t, p, stamp(17))
+
+			// Nothing matches, so it stays where it was
+			if err := p.SeekLap(7); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("got %v seeking to a lap that isn't there, want ErrNotFound", err)
+			}
+			mustNext(t, p, stamp(18))
+
+			// Past the end stops at the last packet
+			if err := p.SeekTime(time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			mustNext(t, p, stamp(59))
+
+			if err := p.SeekTime(0); err != nil {
+				t.Fatal(err)
+			}
+			mustNext(t, p, stamp(0))
+		})
+	}
+}
+
+// Seeking while paused shows the packet it landed on and stays paused
+func TestSeekWhilePaused(t *testing.T) {
+	p := memoryPlayer(t, session(60), Options{})
+	mustNext(t, p, stamp(0))
+	p.Pause()
+	if err := p.SeekTime(20 * interval); err != nil {
+		t.Fatal(err)
+	}
+	mustNext(t, p, stamp(20))
+	if _, err := next(p, 50*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v after seeking while paused, want to stay paused", err)
+	}
+}
+
+func TestLoop(t *testing.T) {
+	const n = 10
+	p := memoryPlayer(t, session(n), Options{Speed: MaxSpeed, Loop: true, HoldAtEnd: true})
+	for i := range 2*n + 5 {
+		mustNext(t, p, stamp(i%n))
+	}
+	if s := p.Status(); s.Loops != 2 || s.Ended || p.Idle() {
+		t.Fatalf("status %+v after going round twice", s)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	const n = 5
+	p := memoryPlayer(t, session(n), Options{Speed: MaxSpeed})
+	for i := range n {
+		mustNext(t, p, stamp(i))
+	}
+	if _, err := next(p, time.Second); err != io.EOF {
+		t.Fatalf("got %v at the end, want io.EOF", err)
+	}
+
+	// Held at the end it waits for a seek, and counts as idle rather than stalled
+	p = memoryPlayer(t, session(n), Options{Speed: MaxSpeed, HoldAtEnd: true})
+	for i := range n {
+		mustNext(t, p, stamp(i))
+	}
+	if _, err := next(p, 50*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v at the end, want to wait", err)
+	}
+	if !p.Status().Ended || !p.Idle() {
+		t.Fatalf("status %+v, idle %t at the end", p.Status(), p.Idle())
+	}
+	if err := p.SeekTime(0); err != nil {
+		t.Fatal(err)
+	}
+	mustNext(t, p, stamp(0))
+	if p.Idle() {
+		t.Fatal("still idle after seeking back")
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	p := memoryPlayer(t, session(2), Options{})
+	if got := p.Faster(); got != 2 {
+		t.Errorf("Faster gave %g", got)
+	}
+	if got := p.SetSpeed(100); got != MaxSpeed {
+		t.Errorf("SetSpeed(100) gave %g", got)
+	}
+	if got := p.SetSpeed(0.01); got != MinSpeed {
+		t.Errorf("SetSpeed(0.01) gave %g", got)
+	}
+	if got := p.Slower(); got != MinSpeed {
+		t.Errorf("Slower at the minimum gave %g", got)
+	}
+}
+
+// Seeks read on the caller's goroutine while whoever called Next is still
+// using the packet it got, which mustn't be overwritten underneath them
+func TestSeekWhileReading(t *testing.T) {
+	p := filePlayer(t, session(60), Options{Speed: MaxSpeed, Loop: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		var parsed packethandling.ForzaHorizon5Packet
+		for {
+			pkt, err := p.Next(ctx)
+			if err != nil {
+				done <- err
+				return
+			}
+			if err := packethandling.ParsePacket(pkt.Data, &parsed); err != nil {
+				done <- err
+				return
+			}
+			time.Sleep(time.Millisecond) // Still looking at it while the seeks happen
+			if err := packethandling.ParsePacket(pkt.Data, &parsed); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+
+	for i := range 50 {
+		if err := p.SeekTime(time.Duration(i%60) * interval); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.SeekLap(uint16(i % 2)); err != nil && !errors.Is(err, ErrNotFound) {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,207 @@
+// Package sequencing watches TimeStampMS to spot dropped, duplicated and
+// reordered packets, which is the only sequence info Forza gives us.
+package sequencing
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often the game sends a packet (60 Hz)
+const DefaultInterval = time.Second / 60
+
+const (
+	// A jump bigger than this (either way) is a new session, e.g. the game
+	// restarted or came back from a menu, rather than lost packets
+	resetThreshold = 2000 // ms
+
+	jitterGain = 16   // RFC 3550 smoothing
+	rateGain   = 0.05 // EWMA weight for the packet rate
+)
+
+// Event is what Observe decided about a packet
+type Event int
+
+const (
+	EventInOrder     Event = iota // Next packet, nothing missing
+	EventFirst                    // First packet, or first after a reset
+	EventGap                      // Arrived after one or more lost packets
+	EventDuplicate                // Same timestamp as the last packet
+	EventOutOfOrder               // Older than the last packet
+	EventReset                    // Timestamp jumped, treated as a new session
+	EventNoTimestamp              // Timestamp is zero, as in the FH5 menu packets
+)
+
+func (e Event) String() string {
+	switch e {
+	case EventInOrder:
+		return "in order"
+	case EventFirst:
+		return "first"
+	case EventGap:
+		return "gap"
+	case EventDuplicate:
+		return "duplicate"
+	case EventOutOfOrder:
+		return "out of order"
+	case EventReset:
+		return "reset"
+	case EventNoTimestamp:
+		return "no timestamp"
+	default:
+		return fmt.Sprintf("Event(%d)", int(e))
+	}
+}
+
+// Stats is a snapshot of the stream health, recordings keep one in their trailer
+type Stats struct {
+	Packets     uint64        `json:"packets"` // Every packet observed
+	Lost        uint64        `json:"lost"`    // Packets missing from the timestamp gaps
+	Duplicates  uint64        `json:"duplicates"`
+	OutOfOrder  uint64        `json:"out_of_order"`
+	Wraps       uint64        `json:"wraps"` // Times TimeStampMS wrapped past its uint32 max
+	Resets      uint64        `json:"resets"`
+	LastPacket  time.Time     `json:"last_packet"`  // When the last packet was received
+	EffectiveHz float64       `json:"effective_hz"` // Smoothed receive rate
+	LossPercent float64       `json:"loss_percent"` // Lost / (received + lost)
+	Jitter      time.Duration `json:"jitter"`       // RFC 3550 style inter-arrival jitter
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%.1f Hz | loss %.1f%% | jitter %.1fms | dup %d | ooo %d",
+		s.EffectiveHz, s.LossPercent, float64(s.Jitter)/float64(time.Millisecond), s.Duplicates, s.OutOfOrder)
+}
+
+// Tracker follows one packet stream, it's safe to read Stats from another goroutine
+type Tracker struct {
+	mu       sync.Mutex
+	interval float64 // expected ms between packets
+
+	started      bool
+	lastStamp    uint32
+	lastReceived time.Time
+	jitter       float64 // ms
+	meanGap      float64 // ms between arrivals
+	stats        Stats
+}
+
+// NewTracker makes a tracker for a stream sending every interval, 0 uses DefaultInterval
+func NewTracker(interval time.Duration) *Tracker {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Tracker{interval: float64(interval) / float64(time.Millisecond)}
+}
+
+// Observe records a packet's TimeStampMS and when it arrived
+func (t *Tracker) Observe(timestamp uint32, received time.Time) Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats.Packets++
+	if !t.stats.LastPacket.IsZero() {
+		t.updateRate(received.Sub(t.stats.LastPacket))
+	}
+	t.stats.LastPacket = received
+
+	// Zero is a menu packet, unless the counter just wrapped onto it
+	if timestamp == 0 && (!t.started || int32(-t.lastStamp) <= 0 || int32(-t.lastStamp) > resetThreshold) {
+		return EventNoTimestamp
+	}
+
+	if !t.started {
+		t.restart(timestamp, received)
+		return EventFirst
+	}
+
+	// Wraparound safe, uint32 maths rolls over the same way the game's counter does
+	delta := int32(timestamp - t.lastStamp)
+
+	switch {
+	case delta == 0:
+		t.stats.Duplicates++
+		return EventDuplicate
+
+	case delta < 0 && delta > -resetThreshold:
+		// A late packet fills in a gap we already counted as lost
+		t.stats.OutOfOrder++
+		if t.stats.Lost > 0 {
+			t.stats.Lost--
+		}
+		t.updateLoss()
+		return EventOutOfOrder
+
+	case delta < 0 || delta > resetThreshold:
+		t.stats.Resets++
+		t.restart(timestamp, received)
+		return EventReset
+	}
+
+	if timestamp < t.lastStamp {
+		t.stats.Wraps++
+	}
+
+	// RFC 3550: difference in transit time between this packet and the last
+	transit := float64(received.Sub(t.lastReceived))/float64(time.Millisecond) - float64(delta)
+	t.jitter += (math.Abs(transit) - t.jitter) / jitterGain
+	t.stats.Jitter = time.Duration(t.jitter * float64(time.Millisecond))
+
+	t.lastStamp = timestamp
+	t.lastReceived = received
+
+	missing := int64(math.Round(float64(delta)/t.interval)) - 1
+	if missing <= 0 {
+		t.updateLoss()
+		return EventInOrder
+	}
+
+	t.stats.Lost += uint64(missing)
+	t.updateLoss()
+	return EventGap
+}
+
+// Stats returns a snapshot of the current stats
+func (t *Tracker) Stats() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// Reset forgets everything, e.g. when switching to a different source
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.started = false
+	t.lastStamp = 0
+	t.lastReceived = time.Time{}
+	t.jitter = 0
+	t.meanGap = 0
+	t.stats = Stats{}
+}
+
+func (t *Tracker) restart(timestamp uint32, received time.Time) {
+	t.started = true
+	t.lastStamp = timestamp
+	t.lastReceived = received
+}
+
+func (t *Tracker) updateRate(gap time.Duration) {
+	ms := float64(gap) / float64(time.Millisecond)
+	if t.meanGap == 0 {
+		t.meanGap = ms
+	} else {
+		t.meanGap += (ms - t.meanGap) * rateGain
+	}
+	if t.meanGap > 0 {
+		t.stats.EffectiveHz = 1000 / t.meanGap
+	}
+}
+
+func (t *Tracker) updateLoss() {
+	received := t.stats.Packets - t.stats.Duplicates
+	if total := received + t.stats.Lost; total > 0 {
+		t.stats.LossPercent = float64(t.stats.Lost) / float64(total) * 100
+	}
+}
@@ -0,0 +1,220 @@
+package sequencing
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const interval = 16 // ms, a whole number so the expected maths is exact
+
+var (
+	epoch           = time.Unix(1700000000, 0)
+	nearWrap uint32 = math.MaxUint32 - 5 // A var so adding to it wraps like the game's counter
+)
+
+// packet is a TimeStampMS and when it arrived, in ms from epoch
+type packet struct {
+	stamp   uint32
+	arrival int
+}
+
+// observe feeds packets through a new tracker and returns the events
+func observe(t *testing.T, packets []packet) (*Tracker, []Event) {
+	t.Helper()
+	tr := NewTracker(interval * time.Millisecond)
+	var events []Event
+	for _, p := range packets {
+		events = append(events, tr.Observe(p.stamp, epoch.Add(time.Duration(p.arrival)*time.Millisecond)))
+	}
+	return tr, events
+}
+
+// steady returns n packets sent and received every interval from stamp
+func steady(stamp uint32, n int) []packet {
+	var packets []packet
+	for i := range n {
+		packets = append(packets, packet{stamp + uint32(i*interval), i * interval})
+	}
+	return packets
+}
+
+func TestEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		packets []packet
+		want    []Event
+		stats   Stats
+	}{
+		{
+			name:    "in order",
+			packets: steady(1000, 3),
+			want:    []Event{EventFirst, EventInOrder, EventInOrder},
+			stats:   Stats{Packets: 3},
+		},
+		{
+			name:    "gap",
+			packets: []packet{{1000, 0}, {1000 + 3*interval, 48}},
+			want:    []Event{EventFirst, EventGap},
+			stats:   Stats{Packets: 2, Lost: 2, LossPercent: 50},
+		},
+		{
+			name:    "duplicate",
+			packets: []packet{{1000, 0}, {1000, 1}, {1000 + interval, 16}},
+			want:    []Event{EventFirst, EventDuplicate, EventInOrder},
+			stats:   Stats{Packets: 3, Duplicates: 1},
+		},
+		{
+			// The late packet fills the gap counted when the one after it arrived
+			name:    "reordered",
+			packets: []packet{{1000, 0}, {1000 + 2*interval, 32}, {1000 + interval, 33}, {1000 + 3*interval, 48}},
+			want:    []Event{EventFirst, EventGap, EventOutOfOrder, EventInOrder},
+			stats:   Stats{Packets: 4, OutOfOrder: 1},
+		},
+		{
+			name:    "wraparound",
+			packets: steady(math.MaxUint32-interval+1, 3),
+			want:    []Event{EventFirst, EventInOrder, EventInOrder},
+			stats:   Stats{Packets: 3, Wraps: 1},
+		},
+		{
+			name:    "wraparound with a gap",
+			packets: []packet{{nearWrap, 0}, {nearWrap + 2*interval, 32}},
+			want:    []Event{EventFirst, EventGap},
+			stats:   Stats{Packets: 2, Lost: 1, Wraps: 1, LossPercent: 100.0 / 3},
+		},
+		{
+			name:    "late packet across the wrap",
+			packets: []packet{{nearWrap, 0}, {nearWrap + 2*interval, 32}, {nearWrap + interval, 33}},
+			want:    []Event{EventFirst, EventGap, EventOutOfOrder},
+			stats:   Stats{Packets: 3, OutOfOrder: 1, Wraps: 1},
+		},
+		{
+			name:    "jump forward",
+			packets: []packet{{1000, 0}, {1000 + resetThreshold + 1, 16}, {1000 + resetThreshold + 1 + interval, 32}},
+			want:    []Event{EventFirst, EventReset, EventInOrder},
+			stats:   Stats{Packets: 3, Resets: 1},
+		},
+		{
+			name:    "jump back",
+			packets: []packet{{100000, 0}, {1000, 16}},
+			want:    []Event{EventFirst, EventReset},
+			stats:   Stats{Packets: 2, Resets: 1},
+		},
+		{
+			name:    "menu packets",
+			packets: []packet{{0, 0}, {0, 16}, {1000, 32}},
+			want:    []Event{EventNoTimestamp, EventNoTimestamp, EventFirst},
+			stats:   Stats{Packets: 3},
+		},
+		{
+			name:    "menu after racing",
+			packets: []packet{{1000, 0}, {0, 16}, {1000 + 2*interval, 32}},
+			want:    []Event{EventFirst, EventNoTimestamp, EventGap},
+			stats:   Stats{Packets: 3, Lost: 1, LossPercent: 100.0 / 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, events := observe(t, tt.packets)
+			for i := range tt.want {
+				if i >= len(events) || events[i] != tt.want[i] {
+					t.Fatalf("got events %v, want %v", events, tt.want)
+				}
+			}
+			got := tr.Stats()
+			got.LastPacket, got.EffectiveHz, got.Jitter = time.Time{}, 0, 0
+			if math.Abs(got.LossPercent-tt.stats.LossPercent) < 1e-9 {
+				got.LossPercent = tt.stats.LossPercent
+			}
+			if got != tt.stats {
+				t.Errorf("got %+v, want %+v", got, tt.stats)
+			}
+		})
+	}
+}
+
+// RFC 3550 section 6.4.1: J += (|D| - J) / 16, where D is the change in transit time
+func TestJitter(t *testing.T) {
+	tests := []struct {
+		name    string
+		packets []packet
+		want    func() float64 // ms
+	}{
+		{"steady", steady(1000, 50), func() float64 { return 0 }},
+		{
+			// A fixed delay on every packet doesn't change the transit time
+			name: "constant delay",
+			packets: func() []packet {
+				packets := steady(1000, 50)
+				for i := range packets {
+					packets[i].arrival += 30
+				}
+				return packets
+			}(),
+			want: func() float64 { return 0 },
+		},
+		{
+			// Every other packet 4ms late, so each |D| is 4
+			name: "alternating",
+			packets: func() []packet {
+				packets := steady(1000, 20)
+				for i := range packets {
+					packets[i].arrival += 4 * (i % 2)
+				}
+				return packets
+			}(),
+			want: func() float64 {
+				var j float64
+				for range 19 {
+					j += (4 - j) / 16
+				}
+				return j
+			},
+		},
+		{
+			// Lost packets don't add jitter, the timestamps account for the gap
+			name:    "gap on time",
+			packets: []packet{{1000, 0}, {1000 + interval, 16}, {1000 + 4*interval, 64}},
+			want:    func() float64 { return 0 },
+		},
+		{
+			// Duplicates and late packets aren't part of the transit time
+			name:    "duplicate",
+			packets: []packet{{1000, 0}, {1000, 10}, {1000 + interval, 16}},
+			want:    func() float64 { return 0 },
+		},
+		{
+			name:    "single late packet",
+			packets: []packet{{1000, 0}, {1000 + interval, 26}, {1000 + 2*interval, 32}},
+			want:    func() float64 { j := 10.0 / 16; return j + (10-j)/16 },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, _ := observe(t, tt.packets)
+			got := float64(tr.Stats().Jitter) / float64(time.Millisecond)
+			if want := tt.want(); math.Abs(got-want) > 1e-6 {
+				t.Errorf("jitter %.6fms, want %.6fms", got, want)
+			}
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	tr, _ := observe(t, []packet{{1000, 0}, {1000 + 5*interval, 80}})
+	tr.Reset()
+	if s := tr.Stats(); s != (Stats{}) {
+		t.Fatalf("got %+v after Reset", s)
+	}
+	if e := tr.Observe(5000, epoch); e != EventFirst {
+		t.Fatalf("got %s after Reset, want first", e)
+	}
+}
+
+func TestEffectiveHz(t *testing.T) {
+	tr, _ := observe(t, steady(1000, 10))
+	if hz := tr.Stats().EffectiveHz; math.Abs(hz-1000.0/interval) > 1e-9 {
+		t.Fatalf("got %v Hz, want %v", hz, 1000.0/interval)
+	}
+}
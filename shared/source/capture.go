@@ -0,0 +1,94 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/pcap"
+	"io"
+	"net"
+	"os"
+)
+
+// Capture plays the Forza packets out of a Wireshark capture (pcap or pcapng)
+type Capture struct {
+	path string
+	port uint16 // Destination port to pick out, 0 takes any UDP payload of a packet size
+	loop bool
+
+	f *os.File
+	r *pcap.Reader
+}
+
+// NewCapture opens a capture, only keeping UDP datagrams sent to port (0 for any port)
+func NewCapture(path string, port int, loop bool) (*Capture, error) {
+	if port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", port)
+	}
+	c := &Capture{path: path, port: uint16(port), loop: loop}
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Capture) open() error {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return err
+	}
+	r, err := pcap.NewReader(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", c.path, err)
+	}
+	if c.f != nil {
+		c.f.Close()
+	}
+	c.f, c.r = f, r
+	return nil
+}
+
+func (c *Capture) Next(ctx context.Context) (Packet, error) {
+	looped := false
+	for {
+		if err := ctx.Err(); err != nil {
+			return Packet{}, err
+		}
+
+		frame, err := c.r.Next()
+		if err == io.EOF && c.loop && !looped {
+			if err := c.open(); err != nil {
+				return Packet{}, err
+			}
+			looped = true
+			continue
+		}
+		if err != nil {
+			return Packet{}, err
+		}
+
+		d, ok := pcap.UDP(frame)
+		if !ok || (c.port != 0 && d.Dst.Port() != c.port) {
+			continue
+		}
+		if packethandling.DetectFormat(len(d.Payload)) == packethandling.FormatUnknown {
+			continue
+		}
+
+		looped = false
+		return Packet{Data: d.Payload, Metadata: Metadata{
+			Source:     c.path,
+			Received:   frame.Timestamp,
+			RemoteAddr: net.UDPAddrFromAddrPort(d.Src),
+		}}, nil
+	}
+}
+
+func (c *Capture) Name() string {
+	return c.path
+}
+
+func (c *Capture) Close() error {
+	return c.f.Close()
+}
@@ -0,0 +1,136 @@
+package source
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+const linkTypeRaw = 101 // Bare IP packets
+
+// datagram is a UDP payload sent to a port
+type datagram struct {
+	port    uint16
+	payload []byte
+}
+
+// writeCapture writes a little endian microsecond pcap of raw IPv4 UDP datagrams
+func writeCapture(t *testing.T, datagrams []datagram) string {
+	t.Helper()
+	le, be := binary.LittleEndian, binary.BigEndian
+	b := le.AppendUint32(nil, 0xa1b2c3d4)
+	b = le.AppendUint16(b, 2)
+	b = le.AppendUint16(b, 4)
+	b = le.AppendUint32(b, 0) // Time zone
+	b = le.AppendUint32(b, 0) // Accuracy
+	b = le.AppendUint32(b, 65535)
+	b = le.AppendUint32(b, linkTypeRaw)
+
+	src := netip.MustParseAddr("192.168.1.20")
+	dst := netip.MustParseAddr("192.168.1.10")
+	for i, d := range datagrams {
+		udp := be.AppendUint16(nil, 50000)
+		udp = be.AppendUint16(udp, d.port)
+		udp = be.AppendUint16(udp, uint16(8+len(d.payload)))
+		udp = append(append(udp, 0, 0), d.payload...)
+
+		ip := []byte{0x45, 0}
+		ip = be.AppendUint16(ip, uint16(20+len(udp)))
+		ip = append(ip, 0, 0, 0, 0, 64, 17, 0, 0)
+		ip = append(append(append(ip, src.AsSlice()...), dst.AsSlice()...), udp...)
+
+		b = le.AppendUint32(b, uint32(1700000000+i))
+		b = le.AppendUint32(b, 0)
+		b = le.AppendUint32(b, uint32(len(ip)))
+		b = le.AppendUint32(b, uint32(len(ip)))
+		b = append(b, ip...)
+	}
+
+	path := filepath.Join(t.TempDir(), "capture.pcap")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCapturePort(t *testing.T) {
+	forza := packets(4)
+	path := writeCapture(t, []datagram{
+		{9999, forza[0]},
+		{5300, forza[1]},
+		{9999, []byte("not a Forza packet")},
+		{9999, forza[2]},
+		{5300, forza[3]},
+	})
+
+	tests := []struct {
+		port int
+		want []uint32
+	}{
+		{9999, []uint32{0, 2}},
+		{5300, []uint32{1, 3}},
+		{0, []uint32{0, 1, 2, 3}},
+		{1234, nil},
+	}
+	for _, tt := range tests {
+		c, err := NewCapture(path, tt.port, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := readStamps(t, c, len(tt.want)+1)
+		c.Close()
+		if !slices.Equal(got, tt.want) || err != io.EOF {
+			t.Errorf("port %d: got %v, %v, want %v then io.EOF", tt.port, got, err, tt.want)
+		}
+	}
+
+	c, err := NewCapture(path, 9999, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	pkt, err := c.Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pkt.Received.Equal(time.Unix(1700000000, 0)) || pkt.RemoteAddr.String() != "192.168.1.20:50000" || pkt.Source != path {
+		t.Errorf("got metadata %+v", pkt.Metadata)
+	}
+}
+
+func TestCaptureLoop(t *testing.T) {
+	forza := packets(2)
+	c, err := NewCapture(writeCapture(t, []datagram{{9999, forza[0]}, {9999, forza[1]}}), 9999, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got, err := readStamps(t, c, 5); err != nil || !slices.Equal(got, []uint32{0, 1, 0, 1, 0}) {
+		t.Fatalf("looping got %v, %v", got, err)
+	}
+
+	// Nothing on the port, so looping would never find anything
+	c, err = NewCapture(writeCapture(t, []datagram{{9999, forza[0]}}), 1234, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Next(context.Background()); err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+}
+
+func TestCaptureBadPort(t *testing.T) {
+	path := writeCapture(t, nil)
+	for _, port := range []int{-1, 65536} {
+		if _, err := NewCapture(path, port, false); err == nil {
+			t.Errorf("port %d wasn't refused", port)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package source
+
+import (
+	"context"
+	"io"
+)
+
+// Memory plays back packets held in memory, handy for synthetic streams
+type Memory struct {
+	name     string
+	packets  [][]byte
+	position int
+	loop     bool
+}
+
+func NewMemory(name string, packets [][]byte, loop bool) *Memory {
+	return &Memory{name: name, packets: packets, loop: loop}
+}
+
+func (m *Memory) Next(ctx context.Context) (Packet, error) {
+	if err := ctx.Err(); err != nil {
+		return Packet{}, err
+	}
+
+	if m.position >= len(m.packets) {
+		if !m.loop || len(m.packets) == 0 {
+			return Packet{}, io.EOF
+		}
+		m.position = 0
+	}
+
+	data := m.packets[m.position]
+	m.position++
+	return Packet{Data: data, Metadata: Metadata{Source: m.name}}, nil
+}
+
+func (m *Memory) Name() string {
+	return m.name
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
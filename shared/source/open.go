@@ -0,0 +1,102 @@
+package source
+
+import (
+	"fmt"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/pcap"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultPacketSize = 324 // Horizon
+
+// Usage describes the URIs Open understands, for flag help text
+const Usage = `Packet source URI:
+  udp://address:port[?rcvbuf=bytes]   listen for the game
+  file://path[?size=324&loop=1]       recording or capture, a bare path works too. size is for
+                                      legacy recordings without a header
+  pcap://path[?port=9999&loop=1]      pcap / pcapng capture, port 0 takes any Forza sized UDP payload
+  stdin://[?size=324] or -            recording piped into stdin`
+
+// Open opens a source from a URI, see Usage
+func Open(uri string) (PacketSource, error) {
+	if uri == "-" {
+		return NewStdin(defaultPacketSize)
+	}
+	if !strings.Contains(uri, "://") {
+		return OpenFile(uri, defaultPacketSize, false)
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source %q: %w", uri, err)
+	}
+	query := u.Query()
+
+	size := defaultPacketSize
+	if s := query.Get("size"); s != "" {
+		if size, err = strconv.Atoi(s); err != nil || packethandling.DetectFormat(size) == packethandling.FormatUnknown {
+			return nil, fmt.Errorf("invalid packet size %q, want 232, 311, 324 or 331", s)
+		}
+	}
+
+	loop := false
+	if s := query.Get("loop"); s != "" {
+		if loop, err = strconv.ParseBool(s); err != nil {
+			return nil, fmt.Errorf("invalid loop %q, want 1 or 0", s)
+		}
+	}
+
+	switch u.Scheme {
+	case "udp":
+		c, err := packethandling.ParseListenConfig(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		if s := query.Get("rcvbuf"); s != "" {
+			if c.ReadBuffer, err = strconv.Atoi(s); err != nil || c.ReadBuffer < 0 {
+				return nil, fmt.Errorf("invalid rcvbuf %q", s)
+			}
+		}
+		return NewUDP([]packethandling.ListenConfig{c})
+
+	case "file":
+		return OpenFile(u.Host+u.Path, size, loop)
+
+	case "pcap", "pcapng":
+		port := 0
+		if s := query.Get("port"); s != "" {
+			if port, err = strconv.Atoi(s); err != nil {
+				return nil, fmt.Errorf("invalid port %q", s)
+			}
+		}
+		return NewCapture(u.Host+u.Path, port, loop)
+
+	case "stdin":
+		return NewStdin(size)
+
+	default:
+		return nil, fmt.Errorf("unknown source type %q", u.Scheme)
+	}
+}
+
+// OpenFile opens a recording, or a capture if the file starts with a pcap /
+// pcapng header. Captures take packets to any port, packetSize is only used
+// for legacy recordings without a header.
+func OpenFile(path string, packetSize int, loop bool) (PacketSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4)
+	n, _ := io.ReadFull(f, header)
+	f.Close()
+
+	if pcap.IsCapture(header[:n]) {
+		return NewCapture(path, 0, loop)
+	}
+	return NewRecording(path, packetSize, loop)
+}
@@ -0,0 +1,114 @@
+package source
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	rec := writeRecording(t, packets(2))
+	capture := writeCapture(t, []datagram{{9999, packets(1)[0]}})
+
+	tests := []struct {
+		uri  string
+		want string // Type of source, or an error it has to contain
+	}{
+		{"udp://127.0.0.1:0", "*source.UDP"},
+		{"udp://127.0.0.1:0?rcvbuf=1048576", "*source.UDP"},
+		{"udp://127.0.0.1:0?rcvbuf=big", "invalid rcvbuf"},
+		{"udp://127.0.0.1:0?rcvbuf=-1", "invalid rcvbuf"},
+		{"udp://127.0.0.1", "invalid listen address"},
+		{"udp://127.0.0.1:70000", "invalid"},
+
+		{rec, "*source.Recording"},
+		{"file://" + rec, "*source.Recording"},
+		{"file://" + rec + "?loop=1&size=232", "*source.Recording"},
+		{"file://" + rec + "?loop=true", "*source.Recording"},
+		{"file://" + rec + "?loop=yes", "invalid loop"},
+		{"file://" + rec + "?size=abc", "invalid packet size"},
+		{"file://" + rec + "?size=100", "invalid packet size"},
+		{"file://" + capture, "*source.Capture"}, // Sniffed from the file
+		{"file:///does/not/exist", "no such file"},
+
+		{"pcap://" + capture, "*source.Capture"},
+		{"pcapng://" + capture + "?port=9999&loop=0", "*source.Capture"},
+		{"pcap://" + capture + "?port=http", "invalid port"},
+		{"pcap://" + capture + "?port=65536", "invalid port"},
+		{"pcap://" + rec, "pcap"},
+
+		{"stdin://", "*source.Recording"},
+		{"-", "*source.Recording"},
+		{"stdin://?size=331", "*source.Recording"},
+		{"stdin://?size=0", "invalid packet size"},
+
+		{"tcp://127.0.0.1:9999", "unknown source type"},
+		{"file://%zz", "invalid source"},
+	}
+	for _, tt := range tests {
+		// stdin gets a recording too, so opening it doesn't wait on the terminal
+		f, err := os.Open(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdin := os.Stdin
+		os.Stdin = f
+
+		src, err := Open(tt.uri)
+		os.Stdin = stdin
+		f.Close()
+
+		switch {
+		case strings.HasPrefix(tt.want, "*"):
+			if err != nil {
+				t.Errorf("%s: %v", tt.uri, err)
+				continue
+			}
+			if got := typeName(src); got != tt.want {
+				t.Errorf("%s: opened a %s, want %s", tt.uri, got, tt.want)
+			}
+			src.Close()
+		case err == nil:
+			src.Close()
+			t.Errorf("%s: opened, want an error containing %q", tt.uri, tt.want)
+		case !strings.Contains(err.Error(), tt.want):
+			t.Errorf("%s: got %v, want an error containing %q", tt.uri, err, tt.want)
+		}
+	}
+}
+
+// The options in the URI make it through to the source
+func TestOpenOptions(t *testing.T) {
+	rec := writeRecording(t, packets(2))
+	src, err := Open("file://" + rec + "?loop=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	if got, err := readStamps(t, src, 3); err != nil || !slices.Equal(got, []uint32{0, 1, 0}) {
+		t.Fatalf("looping got %v, %v", got, err)
+	}
+
+	src, err = Open("udp://telemetry=127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	if src.Name() != "telemetry" {
+		t.Fatalf("named %q", src.Name())
+	}
+}
+
+func typeName(src PacketSource) string {
+	switch src.(type) {
+	case *UDP:
+		return "*source.UDP"
+	case *Recording:
+		return "*source.Recording"
+	case *Capture:
+		return "*source.Capture"
+	default:
+		return "something else"
+	}
+}
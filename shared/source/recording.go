@@ -0,0 +1,97 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"forza-horizon-5-telemetry/shared/recording"
+	"io"
+	"os"
+)
+
+// Recording plays back a recording, or a legacy file of back to back packets,
+// from a file or a stream. Compressed recordings are decompressed on the fly.
+type Recording struct {
+	name       string
+	path       string // Empty for streams, which can't loop
+	legacySize int
+	loop       bool
+	r          *recording.Reader
+}
+
+// NewRecording opens a recording, legacySize is the packet size for files without a header
+func NewRecording(path string, legacySize int, loop bool) (*Recording, error) {
+	r, err := recording.Open(path, legacySize)
+	if err != nil {
+		return nil, err
+	}
+	return &Recording{name: path, path: path, legacySize: legacySize, loop: loop, r: r}, nil
+}
+
+// NewStream reads a recording from r, e.g. a pipe
+func NewStream(name string, r io.Reader, legacySize int) (*Recording, error) {
+	rr, err := recording.NewReader(r, legacySize)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &Recording{name: name, legacySize: legacySize, r: rr}, nil
+}
+
+// NewStdin reads a recording piped into stdin. A blocked read on stdin can't be
+// interrupted, so cancelling only takes effect once the next packet arrives.
+func NewStdin(legacySize int) (*Recording, error) {
+	return NewStream("stdin", os.Stdin, legacySize)
+}
+
+// Header returns the recording's header
+func (r *Recording) Header() recording.Header {
+	return r.r.Header()
+}
+
+func (r *Recording) Next(ctx context.Context) (Packet, error) {
+	if err := ctx.Err(); err != nil {
+		return Packet{}, err
+	}
+
+	rec, err := r.r.NextPacket()
+	if err == io.EOF && r.loop && r.path != "" {
+		// Loop back to the start
+		if err := r.reopen(); err != nil {
+			return Packet{}, err
+		}
+		rec, err = r.r.NextPacket()
+		if err == io.EOF {
+			return Packet{}, fmt.Errorf("%s: no packets to loop", r.name)
+		}
+	}
+	if err != nil {
+		if err != io.EOF {
+			// Damage is skipped, the next call carries on after it
+			err = fmt.Errorf("%s: %w", r.name, err)
+		}
+		return Packet{}, err
+	}
+	return Packet{Data: rec.Data, Metadata: Metadata{Source: r.name, Received: rec.Received}}, nil
+}
+
+func (r *Recording) reopen() error {
+	nr, err := recording.Open(r.path, r.legacySize)
+	if err != nil {
+		return err
+	}
+	r.r.Close()
+	r.r = nr
+	return nil
+}
+
+// Seek jumps to an index entry
+func (r *Recording) Seek(e recording.Entry) error {
+	return r.r.SeekOffset(e.Offset)
+}
+
+func (r *Recording) Name() string {
+	return r.name
+}
+
+func (r *Recording) Close() error {
+	return r.r.Close()
+}
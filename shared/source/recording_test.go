@@ -0,0 +1,60 @@
+package source
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestRecording(t *testing.T) {
+	path := writeRecording(t, packets(3))
+
+	r, err := NewRecording(path, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got, err := readStamps(t, r, 4); !slices.Equal(got, []uint32{0, 1, 2}) || err != io.EOF {
+		t.Fatalf("got %v, %v, want 3 packets then io.EOF", got, err)
+	}
+
+	// Looping opens the file again at the end
+	r, err = NewRecording(path, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got, err := readStamps(t, r, 8); err != nil || !slices.Equal(got, []uint32{0, 1, 2, 0, 1, 2, 0, 1}) {
+		t.Fatalf("looping got %v, %v", got, err)
+	}
+}
+
+// A recording with nothing in it fails rather than looping forever
+func TestRecordingLoopEmpty(t *testing.T) {
+	r, err := NewRecording(writeRecording(t, nil), 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := readStamps(t, r, 1); err == nil || err == io.EOF {
+		t.Fatalf("got %v, want an error", err)
+	}
+}
+
+// Streams can't be opened again, so they end even when looping was asked for
+func TestStream(t *testing.T) {
+	b, err := os.ReadFile(writeRecording(t, packets(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewStream("pipe", bytes.NewReader(b), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.loop = true
+	if got, err := readStamps(t, r, 3); !slices.Equal(got, []uint32{0, 1}) || err != io.EOF {
+		t.Fatalf("got %v, %v, want 2 packets then io.EOF", got, err)
+	}
+}
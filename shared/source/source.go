@@ -0,0 +1,40 @@
+// Package source gives every tool one way to read packets, whether they come
+// from the game over UDP, a recording, a pipe or memory.
+package source
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Metadata is where and when a packet came from
+type Metadata struct {
+	Source     string    // Name of the source it came from, e.g. udp://127.0.0.1:9999 or a file path
+	Received   time.Time // When it arrived, zero if the source doesn't know
+	RemoteAddr net.Addr  // Who sent it, nil for anything that isn't a socket
+}
+
+// Packet is one raw datagram. Data is only valid until the next call to Next.
+type Packet struct {
+	Data []byte
+	Metadata
+}
+
+// PacketSource is anything packets can be read from
+type PacketSource interface {
+	// Next blocks until the next packet is ready. Returns io.EOF when the source
+	// is finished and the context's error if it's cancelled.
+	Next(ctx context.Context) (Packet, error)
+
+	// Name is a human readable name for the source, normally its URI
+	Name() string
+
+	Close() error
+}
+
+// Idler is a source that can hold packets back on purpose, like a paused
+// replay. Idle is true while it is, so the quiet isn't taken for a stall.
+type Idler interface {
+	Idle() bool
+}
@@ -0,0 +1,82 @@
+package source
+
+import (
+	"context"
+	"encoding/binary"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"forza-horizon-5-telemetry/shared/recording"
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// packets returns n Horizon packets numbered by TimeStampMS from 0
+func packets(n int) [][]byte {
+	var out [][]byte
+	for i := range n {
+		p := packethandling.ForzaHorizon5Packet{TimeStampMS: uint32(i)}
+		out = append(out, packethandling.MarshalPacket(&p))
+	}
+	return out
+}
+
+// writeRecording writes packets to a recording in a temp dir and returns its path
+func writeRecording(t *testing.T, packets [][]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session")
+	w, err := recording.Create(path, recording.Header{Format: packethandling.FormatHorizon, PacketSize: packethandling.FormatHorizon.Size()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range packets {
+		if err := w.WritePacket(p, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readStamps reads n packets and returns their TimeStampMS, then the error after them
+func readStamps(t *testing.T, src PacketSource, n int) ([]uint32, error) {
+	t.Helper()
+	var stamps []uint32
+	for range n {
+		pkt, err := src.Next(context.Background())
+		if err != nil {
+			return stamps, err
+		}
+		stamps = append(stamps, binary.LittleEndian.Uint32(pkt.Data[4:]))
+	}
+	return stamps, nil
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory("synthetic", packets(3), false)
+	got, err := readStamps(t, m, 4)
+	if !slices.Equal(got, []uint32{0, 1, 2}) || err != io.EOF {
+		t.Fatalf("got %v, %v, want 3 packets then io.EOF", got, err)
+	}
+	if _, err := m.Next(context.Background()); err != io.EOF {
+		t.Fatalf("got %v after the end", err)
+	}
+
+	m = NewMemory("synthetic", packets(3), true)
+	if got, err := readStamps(t, m, 7); err != nil || !slices.Equal(got, []uint32{0, 1, 2, 0, 1, 2, 0}) {
+		t.Fatalf("looping got %v, %v", got, err)
+	}
+
+	if _, err := NewMemory("empty", nil, true).Next(context.Background()); err != io.EOF {
+		t.Fatalf("looping nothing got %v, want io.EOF", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewMemory("synthetic", packets(3), true).Next(ctx); err != context.Canceled {
+		t.Fatalf("got %v with a cancelled context", err)
+	}
+}
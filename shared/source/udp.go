@@ -0,0 +1,49 @@
+package source
+
+import (
+	"context"
+	"forza-horizon-5-telemetry/shared/packethandling"
+	"net"
+	"strings"
+)
+
+// UDP reads live packets from one or more listeners
+type UDP struct {
+	listener *packethandling.MultiListener
+	name     string
+}
+
+// NewUDP starts listening on every config
+func NewUDP(configs []packethandling.ListenConfig) (*UDP, error) {
+	listener, err := packethandling.ListenAll(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(configs))
+	for i, c := range configs {
+		names[i] = c.SourceName()
+	}
+	return &UDP{listener: listener, name: strings.Join(names, ", ")}, nil
+}
+
+func (u *UDP) Next(ctx context.Context) (Packet, error) {
+	d, err := u.listener.ReadContext(ctx)
+	if err != nil {
+		return Packet{}, err
+	}
+	return Packet{Data: d.Data, Metadata: Metadata{Source: d.Source, Received: d.Received, RemoteAddr: d.Addr}}, nil
+}
+
+func (u *UDP) Name() string {
+	return u.name
+}
+
+// Conns returns every listener's socket, e.g. for a relay to send from and not loop back to
+func (u *UDP) Conns() []*net.UDPConn {
+	return u.listener.Conns()
+}
+
+func (u *UDP) Close() error {
+	return u.listener.Close()
+}
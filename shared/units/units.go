@@ -0,0 +1,240 @@
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Conversion factors, these are the exact definitions not approximations
+const (
+	KMHPerMPS   = 3.6
+	MetersPerMi = 1609.344
+	MPHPerMPS   = 3600 / MetersPerMi
+	WattsPerKW  = 1000
+	WattsPerHP  = 745.69987158227022 // Mechanical horsepower (550 ft·lbf/s)
+	WattsPerPS  = 735.49875          // Metric horsepower
+	NMPerLbFt   = 1.3558179483314004
+	PaPerPSI    = 6894.757293168361
+	PaPerBar    = 100000
+)
+
+// Forza sends speed in m/s
+func MPSToKMH(mps float64) float64 { return mps * KMHPerMPS }
+func MPSToMPH(mps float64) float64 { return mps * MPHPerMPS }
+
+// Forza sends power in Watts
+func WattsToKW(w float64) float64 { return w / WattsPerKW }
+func WattsToHP(w float64) float64 { return w / WattsPerHP }
+func WattsToPS(w float64) float64 { return w / WattsPerPS }
+
+// Forza sends torque in N·m
+func NMToLbFt(nm float64) float64 { return nm / NMPerLbFt }
+
+// Forza sends tire temperatures in °F
+func FahrenheitToCelsius(f float64) float64 { return (f - 32) * 5 / 9 }
+
+// Forza sends boost in psi
+func PSIToBar(psi float64) float64 { return psi * PaPerPSI / PaPerBar }
+
+// System is a metric / imperial preference
+type System int
+
+const (
+	Metric System = iota
+	Imperial
+)
+
+func (s System) String() string {
+	if s == Imperial {
+		return "imperial"
+	}
+	return "metric"
+}
+
+// ParseSystem parses "metric" or "imperial"
+func ParseSystem(s string) (System, error) {
+	switch strings.ToLower(s) {
+	case "metric":
+		return Metric, nil
+	case "imperial":
+		return Imperial, nil
+	default:
+		return Metric, fmt.Errorf("unknown unit system %q, want metric or imperial", s)
+	}
+}
+
+type SpeedUnit int
+
+const (
+	KMH SpeedUnit = iota
+	MPH
+	MPS
+)
+
+func (u SpeedUnit) String() string {
+	switch u {
+	case MPH:
+		return "mph"
+	case MPS:
+		return "m/s"
+	default:
+		return "km/h"
+	}
+}
+
+// FromMPS converts a speed in m/s to this unit
+func (u SpeedUnit) FromMPS(mps float64) float64 {
+	switch u {
+	case MPH:
+		return MPSToMPH(mps)
+	case MPS:
+		return mps
+	default:
+		return MPSToKMH(mps)
+	}
+}
+
+type PowerUnit int
+
+const (
+	KW PowerUnit = iota
+	HP
+	PS
+	Watts
+)
+
+func (u PowerUnit) String() string {
+	switch u {
+	case HP:
+		return "hp"
+	case PS:
+		return "PS"
+	case Watts:
+		return "W"
+	default:
+		return "kW"
+	}
+}
+
+// FromWatts converts a power in Watts to this unit
+func (u PowerUnit) FromWatts(w float64) float64 {
+	switch u {
+	case HP:
+		return WattsToHP(w)
+	case PS:
+		return WattsToPS(w)
+	case Watts:
+		return w
+	default:
+		return WattsToKW(w)
+	}
+}
+
+type TorqueUnit int
+
+const (
+	NM TorqueUnit = iota
+	LbFt
+)
+
+func (u TorqueUnit) String() string {
+	if u == LbFt {
+		return "lb-ft"
+	}
+	return "N·m"
+}
+
+// FromNM converts a torque in N·m to this unit
+func (u TorqueUnit) FromNM(nm float64) float64 {
+	if u == LbFt {
+		return NMToLbFt(nm)
+	}
+	return nm
+}
+
+type TemperatureUnit int
+
+const (
+	Celsius TemperatureUnit = iota
+	Fahrenheit
+)
+
+func (u TemperatureUnit) String() string {
+	if u == Fahrenheit {
+		return "°F"
+	}
+	return "°C"
+}
+
+// FromFahrenheit converts a temperature in °F to this unit
+func (u TemperatureUnit) FromFahrenheit(f float64) float64 {
+	if u == Fahrenheit {
+		return f
+	}
+	return FahrenheitToCelsius(f)
+}
+
+type PressureUnit int
+
+const (
+	Bar PressureUnit = iota
+	PSI
+)
+
+func (u PressureUnit) String() string {
+	if u == PSI {
+		return "psi"
+	}
+	return "bar"
+}
+
+// FromPSI converts a pressure in psi to this unit
+func (u PressureUnit) FromPSI(psi float64) float64 {
+	if u == PSI {
+		return psi
+	}
+	return PSIToBar(psi)
+}
+
+// Preferences is which unit to show each kind of value in
+type Preferences struct {
+	Speed       SpeedUnit
+	Power       PowerUnit
+	Torque      TorqueUnit
+	Temperature TemperatureUnit
+	Pressure    PressureUnit
+}
+
+// ForSystem returns the usual units for a system
+func ForSystem(s System) Preferences {
+	if s == Imperial {
+		return Preferences{Speed: MPH, Power: HP, Torque: LbFt, Temperature: Fahrenheit, Pressure: PSI}
+	}
+	return Preferences{Speed: KMH, Power: KW, Torque: NM, Temperature: Celsius, Pressure: Bar}
+}
+
+// The Format functions are the only place values get rounded, everything
+// before display works on the exact values.
+
+func (p Preferences) FormatSpeed(mps float64) string {
+	return fmt.Sprintf("%.0f %s", p.Speed.FromMPS(mps), p.Speed)
+}
+
+func (p Preferences) FormatPower(w float64) string {
+	return fmt.Sprintf("%.0f %s", p.Power.FromWatts(w), p.Power)
+}
+
+func (p Preferences) FormatTorque(nm float64) string {
+	return fmt.Sprintf("%.0f %s", p.Torque.FromNM(nm), p.Torque)
+}
+
+func (p Preferences) FormatTemperature(f float64) string {
+	return fmt.Sprintf("%.0f %s", p.Temperature.FromFahrenheit(f), p.Temperature)
+}
+
+func (p Preferences) FormatPressure(psi float64) string {
+	if p.Pressure == Bar {
+		return fmt.Sprintf("%.2f %s", p.Pressure.FromPSI(psi), p.Pressure)
+	}
+	return fmt.Sprintf("%.1f %s", p.Pressure.FromPSI(psi), p.Pressure)
+}
@@ -0,0 +1,118 @@
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+// Units from their definitions: the international yard and pound and standard gravity
+const (
+	foot    = 0.3048     // m
+	inch    = 0.0254     // m
+	pound   = 0.45359237 // kg
+	gravity = 9.80665    // m/s²
+	lbf     = pound * gravity
+)
+
+func near(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-12*math.Max(1, math.Abs(want))
+}
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		name     string
+		convert  func(float64) float64
+		in, want float64
+	}{
+		{"m/s to km/h", MPSToKMH, 10, 36},
+		{"m/s to km/h", MPSToKMH, 0, 0},
+		{"m/s to mph", MPSToMPH, 26.8224, 60},
+		{"m/s to mph", MPSToMPH, 1609.344 / 3600, 1},
+		{"W to kW", WattsToKW, 1500, 1.5},
+		{"W to hp", WattsToHP, 550 * foot * lbf, 1},
+		{"W to hp", WattsToHP, 100000, 100000 / (550 * foot * lbf)},
+		{"W to PS", WattsToPS, 75 * gravity, 1},
+		{"N·m to lb-ft", NMToLbFt, foot * lbf, 1},
+		{"N·m to lb-ft", NMToLbFt, 400, 400 / (foot * lbf)},
+		{"°F to °C", FahrenheitToCelsius, 32, 0},
+		{"°F to °C", FahrenheitToCelsius, 212, 100},
+		{"°F to °C", FahrenheitToCelsius, -40, -40},
+		{"psi to bar", PSIToBar, 1, lbf / (inch * inch) / 100000},
+		{"psi to bar", PSIToBar, 100000 / (lbf / (inch * inch)), 1},
+	}
+	for _, tt := range tests {
+		if got := tt.convert(tt.in); !near(got, tt.want) {
+			t.Errorf("%s: %v gave %v, want %v", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSystem(t *testing.T) {
+	tests := []struct {
+		in   string
+		want System
+		ok   bool
+	}{
+		{"metric", Metric, true},
+		{"imperial", Imperial, true},
+		{"Imperial", Imperial, true},
+		{"METRIC", Metric, true},
+		{"", Metric, false},
+		{"us", Metric, false},
+		{" metric", Metric, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSystem(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("%q: got %s, %v", tt.in, got, err)
+		}
+		if err == nil && got.String() != tt.want.String() {
+			t.Errorf("%q: String gave %s", tt.in, got)
+		}
+	}
+}
+
+func TestForSystem(t *testing.T) {
+	if got, want := ForSystem(Metric), (Preferences{Speed: KMH, Power: KW, Torque: NM, Temperature: Celsius, Pressure: Bar}); got != want {
+		t.Errorf("metric: got %+v", got)
+	}
+	if got, want := ForSystem(Imperial), (Preferences{Speed: MPH, Power: HP, Torque: LbFt, Temperature: Fahrenheit, Pressure: PSI}); got != want {
+		t.Errorf("imperial: got %+v", got)
+	}
+}
+
+// Values are only rounded for display, to whole units apart from pressure
+func TestFormat(t *testing.T) {
+	metric, imperial := ForSystem(Metric), ForSystem(Imperial)
+	tests := []struct {
+		got, want string
+	}{
+		{metric.FormatSpeed(10), "36 km/h"},
+		{metric.FormatSpeed(10.1388), "36 km/h"}, // 36.49968
+		{metric.FormatSpeed(10.1389), "37 km/h"}, // 36.50004
+		{imperial.FormatSpeed(26.8224), "60 mph"},
+		{Preferences{Speed: MPS}.FormatSpeed(12.6), "13 m/s"},
+
+		{metric.FormatPower(100000), "100 kW"},
+		{imperial.FormatPower(100000), "134 hp"},               // 134.102
+		{Preferences{Power: PS}.FormatPower(100000), "136 PS"}, // 135.962
+		{Preferences{Power: Watts}.FormatPower(1234.4), "1234 W"},
+
+		{metric.FormatTorque(400.4), "400 N·m"},
+		{imperial.FormatTorque(400), "295 lb-ft"}, // 295.02
+
+		{metric.FormatTemperature(212), "100 °C"},
+		{metric.FormatTemperature(180), "82 °C"}, // 82.22
+		{imperial.FormatTemperature(180.6), "181 °F"},
+
+		{metric.FormatPressure(14.5), "1.00 bar"}, // 0.99974
+		{metric.FormatPressure(20), "1.38 bar"},   // 1.37895
+		{imperial.FormatPressure(14.46), "14.5 psi"},
+		{imperial.FormatPressure(-2.04), "-2.0 psi"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}